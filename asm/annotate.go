@@ -0,0 +1,127 @@
+package asm
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// Annotated whitespace has the assembly for each instruction written in
+// front of its whitespace, as the mnemonic and operand joined with a colon:
+//
+//	push:1   \t\nlabel:loop\n   \nprintnumber\t\n \tjump:loop\n \n \n
+//
+// Anything that isn't whitespace is ignored when the program is run, so it
+// is still the same program.
+
+// names returns the label names of the program by their encodings.
+func (p *Program) names() map[string]string {
+	names := make(map[string]string)
+	for _, sym := range p.Symbols {
+		names[sym.Encoding] = sym.Name
+	}
+	return names
+}
+
+// WriteAnnotated writes the whitespace for the program with the assembly of
+// each instruction in front of it, using label names where they are known.
+func (p *Program) WriteAnnotated(w io.Writer, dialect *inst.Dialect) error {
+	return Annotate(w, p.Instructions, p.names(), dialect)
+}
+
+// Annotate writes the whitespace for prog with the assembly of each
+// instruction in front of it.  names has label names by their encodings
+// and can be nil.
+func Annotate(w io.Writer, prog []inst.Instruction, names map[string]string, dialect *inst.Dialect) error {
+	bw := bufio.NewWriter(w)
+	for _, i := range prog {
+		bw.WriteString(dialect.Mnemonic(i.Type()))
+		if operand := operand(i, names); operand != "" {
+			bw.WriteString(":" + operand)
+		}
+		bw.WriteString(i.Wsp())
+	}
+	return bw.Flush()
+}
+
+// operand returns the operand of i as it is written in assembly, with the
+// name of its label if there is one.
+func operand(i inst.Instruction, names map[string]string) string {
+	if fc, ok := i.(inst.FlowControl); ok {
+		if name, ok := names[fc.Label()]; ok {
+			return name
+		}
+	}
+
+	_, operand, _ := strings.Cut(i.Asm(), " ")
+	return operand
+}
+
+// AnnotatedNames returns the label names found in the annotations of a
+// program, by their encodings.  annotations has the text in front of each
+// instruction.  Annotations that don't match their instruction, names that
+// can't be assembled, and names given to more than one label are ignored.
+func AnnotatedNames(prog []inst.Instruction, annotations []string) map[string]string {
+	names := make(map[string]string)
+	labels := make(map[string]string) // name to encoding
+
+	for idx, i := range prog {
+		fc, ok := i.(inst.FlowControl)
+		if !ok || idx >= len(annotations) {
+			continue
+		}
+
+		mnemonic, name, found := strings.Cut(annotations[idx], ":")
+		if !found || !isMnemonic(mnemonic, i.Type()) || !isLabelName(name) || isLiteralLabel(name) {
+			continue
+		}
+
+		if enc, exist := labels[name]; exist && enc != fc.Label() {
+			continue
+		}
+		if _, exist := names[fc.Label()]; exist {
+			continue
+		}
+
+		names[fc.Label()] = name
+		labels[name] = fc.Label()
+	}
+	return names
+}
+
+// isMnemonic returns true if mnemonic is c in any dialect.
+func isMnemonic(mnemonic string, c inst.Command) bool {
+	for _, d := range inst.Dialects {
+		if m, ok := d.Command(mnemonic); ok && m == c {
+			return true
+		}
+	}
+	return false
+}
+
+// Disassemble writes the assembly for prog in dialect, using label names
+// where they are known.  names has label names by their encodings and can
+// be nil.
+func Disassemble(w io.Writer, prog []inst.Instruction, names map[string]string, dialect *inst.Dialect) error {
+	bw := bufio.NewWriter(w)
+	for _, i := range prog {
+		fc, ok := i.(inst.FlowControl)
+		name, named := "", false
+		if ok {
+			name, named = names[fc.Label()]
+		}
+
+		switch {
+		case !named:
+			bw.WriteString(dialect.Asm(i))
+		case i.Type() == inst.CmdLabel && dialect.LabelColon:
+			bw.WriteString(name + ":")
+		default:
+			bw.WriteString(dialect.Mnemonic(i.Type()) + " " + name)
+		}
+		bw.WriteString("\n")
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,103 @@
+package asm
+
+import (
+	"strings"
+	"testing"
+
+	ws "github.com/zorchenhimer/whitespace"
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+func TestAnnotate(t *testing.T) {
+	src := `main:
+	push 2
+1:
+	jumpzero 1f
+	jump main
+1:
+	stop
+`
+	prog, err := NewAssembler(strings.NewReader(src), "count.wsa").Assemble()
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	buf := &strings.Builder{}
+	if err := prog.WriteAnnotated(buf, inst.Native); err != nil {
+		t.Fatalf("WriteAnnotated() error: %s", err)
+	}
+
+	expected := "label:main\n   \npush:2   \t \nlabel:t\n  \t\n" +
+		"jumpzero:ss\n\t   \njump:main\n \n \nlabel:ss\n    \nstop\n\n\n"
+	if buf.String() != expected {
+		t.Fatalf("Unexpected annotation.\n Rec: %q\n Exp: %q", buf.String(), expected)
+	}
+
+	cst, err := ws.ParseCST(strings.NewReader(buf.String()))
+	if err != nil {
+		t.Fatalf("ParseCST() error: %s", err)
+	}
+
+	if cst.Trailing != "" || len(cst.Nodes) != len(prog.Instructions) {
+		t.Fatalf("Unexpected CST: %d nodes, trailing %q", len(cst.Nodes), cst.Trailing)
+	}
+
+	annotations := []string{}
+	for i, n := range cst.Nodes {
+		if n.Instruction.Wsp() != prog.Instructions[i].Wsp() {
+			t.Fatalf("Unexpected instruction %d: %q", i, n.Instruction.Asm())
+		}
+		annotations = append(annotations, n.Leading)
+	}
+
+	// numeric labels can't be assembled by name
+	names := AnnotatedNames(cst.Instructions(), annotations)
+	if len(names) != 1 || names[" "] != "main" {
+		t.Fatalf("Unexpected names: %q", names)
+	}
+
+	out := &strings.Builder{}
+	if err := Disassemble(out, cst.Instructions(), names, inst.Short); err != nil {
+		t.Fatalf("Disassemble() error: %s", err)
+	}
+
+	expected = "main:\npush 2\nt:\njz ss\njump main\nss:\nend\n"
+	if out.String() != expected {
+		t.Fatalf("Unexpected disassembly.\n Rec: %q\n Exp: %q", out.String(), expected)
+	}
+}
+
+func TestAnnotatedNames(t *testing.T) {
+	prog := []inst.Instruction{
+		&inst.Label{Value: " "},
+		&inst.Call{Value: " "},
+		&inst.Jump{Value: "\t"},
+		&inst.Label{Value: "\t"},
+		&inst.JumpZero{Value: "  "},
+		&inst.Call{Value: "\t\t"},
+		&inst.Add{},
+	}
+
+	annotations := []string{
+		"label:main",
+		"call:other", // the label already has a name
+		"jump:main",  // the name is already used
+		"jmp:second", // jmp isn't jump in any dialect
+		"jz:third",   // the short dialect
+		"call:tst",   // literal
+		"add:fourth", // not flow control
+	}
+
+	names := AnnotatedNames(prog, annotations)
+	expected := map[string]string{" ": "main", "  ": "third"}
+
+	if len(names) != len(expected) {
+		t.Fatalf("Unexpected names: %q", names)
+	}
+	for enc, name := range expected {
+		if names[enc] != name {
+			t.Logf("Unexpected name for %q: %q", enc, names[enc])
+			t.Fail()
+		}
+	}
+}
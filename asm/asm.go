@@ -0,0 +1,204 @@
+// Package asm turns the whitespace assembly representation into
+// instructions.
+//
+// Each line holds one instruction, its mnemonic followed by an optional
+// operand.  Everything after a # is a comment.  Mnemonics are not case
+// sensitive, label names are.
+//
+// A line with only name: defines a label.  Labels starting with a period are
+// local to the last global label, and numeric labels like 1: can be defined
+// more than once and referenced with 1f for the next and 1b for the previous.
+//
+// Numbers can be decimal, or hex, octal, or binary with a 0x, 0o, or 0b
+// prefix.  A character in single quotes is its code point.  The .print and
+// .pushstr pseudo-instructions take a string in double quotes.
+//
+// The .data, .words, and .zero directives set values in the heap before the
+// first instruction runs.  Each takes an address followed by a string, a
+// list of numbers, or a count of zeros.
+//
+// Constants are defined with .equ NAME VALUE.  Anywhere a number is expected
+// an expression can be used instead, made of numbers, characters, constants,
+// parentheses, and the operators + - * / % << and >>.
+//
+// .var NAME and .array NAME SIZE reserve heap space at the lowest free
+// address and define NAME as a constant holding it.  load ADDR and store ADDR
+// push the address before loading or storing the value on the stack.
+//
+// Macros are defined with .macro and .endm.  Inside the body \name is
+// replaced with the argument for that parameter and \@ with a number unique
+// to each expansion, which is useful for labels.  Arguments are separated
+// with commas.
+//
+//	.macro printc c
+//		push \c
+//		printchar
+//	.endm
+//
+//	printc 72
+//
+// Other files can be included with .include "filename".  The file is
+// looked for in the directory of the file including it, then in each of
+// the include paths.  A file containing .once is only included once.
+//
+// Lines between .ifdef NAME and .endif are only assembled if NAME has been
+// given to .define, and the opposite for .ifndef.  Either can have an
+// .else.  Defined names are replaced with their value in operands.
+package asm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// Program is the result of assembling a source file.
+type Program struct {
+	Instructions []inst.Instruction
+
+	// Source position of each instruction.
+	Positions []Position
+
+	// Named labels in the order they are defined.  Literal and numeric
+	// labels are not included.
+	Symbols []Symbol
+
+	// Numeric labels in the order they are defined, named as they are
+	// written, so the same name can have more than one encoding.
+	NumericLabels []Symbol
+
+	// Heap space reserved with .var and .array, in the order they are
+	// declared.
+	Variables []Variable
+
+	filename string              // the main source file
+	sources  map[string][]string // lines of each source file, for listings
+}
+
+// Symbol is a named label and the encoding that was assigned to it.
+type Symbol struct {
+	Name     string
+	Encoding string // without the terminating newline
+	Pos      Position
+}
+
+// Wsp returns the whitespace for the whole program.
+func (p *Program) Wsp() string {
+	sb := &strings.Builder{}
+	for _, i := range p.Instructions {
+		sb.WriteString(i.Wsp())
+	}
+	return sb.String()
+}
+
+// WriteSymbols writes each named label and its encoding, with S for space
+// and T for tab, one per line.
+func (p *Program) WriteSymbols(w io.Writer) error {
+	for _, sym := range p.Symbols {
+		_, err := fmt.Fprintf(w, "%s %s\n", sym.Name, inst.DecodeLabel(sym.Encoding))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LabelNames returns the name of every label that was given an encoding
+// by the assembler, including numeric labels, by their encodings.  Unlike
+// the names in Symbols, numeric labels can't be assembled by these names.
+func (p *Program) LabelNames() map[string]string {
+	names := p.names()
+	for _, sym := range p.NumericLabels {
+		names[sym.Encoding] = sym.Name
+	}
+	return names
+}
+
+type Assembler struct {
+	// Directories to search for files given to .include, after the
+	// directory of the file doing the including.
+	IncludePaths []string
+
+	// Names defined before assembling starts, like .define.  Names are
+	// replaced with their values in operands.
+	Defines map[string]string
+
+	// Mnemonics to accept along with the native ones.  Defaults to
+	// inst.Native.
+	Dialect *inst.Dialect
+
+	r        io.Reader
+	filename string
+	errors   ErrorList
+
+	macros     map[string]*macro
+	expansions int // number of macro expansions so far
+
+	including []string        // files currently being included, outermost first
+	once      map[string]bool // files that contain .once
+
+	regions []*region // heap set by data directives
+	fixed   []*region // every data directive, for placing variables
+	sources map[string][]string
+
+	constants   map[string]int64 // from .equ
+	constantPos map[string]Position
+}
+
+// NewAssembler returns an assembler for the source in reader.  The filename
+// is only used for positions and may be empty.
+func NewAssembler(reader io.Reader, filename string) *Assembler {
+	return &Assembler{
+		r:        reader,
+		filename: filename,
+		Defines:  make(map[string]string),
+		macros:   make(map[string]*macro),
+		once:     make(map[string]bool),
+		sources:  make(map[string][]string),
+
+		constants:   make(map[string]int64),
+		constantPos: make(map[string]Position),
+	}
+}
+
+// Assemble is shorthand for NewAssembler(reader, "").Assemble().
+func Assemble(reader io.Reader) (*Program, error) {
+	return NewAssembler(reader, "").Assemble()
+}
+
+// Assemble reads and assembles the whole source.  If there are any
+// problems with the source the error is an ErrorList.
+func (a *Assembler) Assemble() (*Program, error) {
+	src, err := io.ReadAll(a.r)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read input: %w", err)
+	}
+
+	if a.Defines == nil {
+		a.Defines = make(map[string]string)
+	}
+
+	if a.filename != "" {
+		a.including = []string{a.fileKey(a.filename)}
+	}
+
+	a.sources[a.filename] = sourceLines(string(src))
+	lines := a.preprocess(splitLines(a.filename, string(src)), 0)
+	prog := a.build(lines)
+	prog.filename = a.filename
+	prog.sources = a.sources
+	if err := a.errors.Err(); err != nil {
+		return nil, err
+	}
+	return prog, nil
+}
+
+func (a *Assembler) errorf(pos Position, format string, args ...interface{}) {
+	e := &Error{Pos: pos, Msg: fmt.Sprintf(format, args...)}
+	if src := a.sources[pos.Filename]; pos.Line > 0 && pos.Line <= len(src) {
+		e.Source = src[pos.Line-1]
+	}
+	a.errors = append(a.errors, e)
+}
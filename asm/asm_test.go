@@ -0,0 +1,529 @@
+package asm
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+func TestAssemble(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Input  string
+		Output []inst.Instruction
+	}{
+		{"Stack", "push 1\npush -2\ncopy 1\nslide 2\nduplicate\nswap\ndiscard", []inst.Instruction{
+			&inst.Push{Value: 1},
+			&inst.Push{Value: -2},
+			&inst.Copy{Value: 1},
+			&inst.Slide{Value: 2},
+			&inst.Duplicate{},
+			&inst.Swap{},
+			&inst.Discard{},
+		}},
+
+		{"Comments and case", "# comment\n\n  PUSH 1 # one\n\tPrintNumber\n", []inst.Instruction{
+			&inst.Push{Value: 1},
+			&inst.PrintNumber{},
+		}},
+
+		{"Literal labels", "label st\ncall ST\njump ts\nlabel ts", []inst.Instruction{
+			&inst.Label{Value: " \t"},
+			&inst.Call{Value: " \t"},
+			&inst.Jump{Value: "\t "},
+			&inst.Label{Value: "\t "},
+		}},
+
+		{"Named labels", "label s\nlabel loop\njumpzero end\njump loop\nlabel end\nstop", []inst.Instruction{
+			&inst.Label{Value: " "},
+			&inst.Label{Value: "\t"},
+			&inst.JumpZero{Value: "  "},
+			&inst.Jump{Value: "\t"},
+			&inst.Label{Value: "  "},
+			&inst.Stop{},
+		}},
+	}
+
+	for _, tst := range tests {
+		t.Log(tst.Name)
+		prog, err := Assemble(strings.NewReader(tst.Input))
+		if err != nil {
+			t.Logf("Assemble() error: %s", err)
+			t.Fail()
+			continue
+		}
+
+		if len(prog.Instructions) != len(tst.Output) || len(prog.Positions) != len(tst.Output) {
+			t.Logf("Unexpected length %d; expected %d", len(prog.Instructions), len(tst.Output))
+			t.Fail()
+			continue
+		}
+
+		for i, exp := range tst.Output {
+			if prog.Instructions[i].Wsp() != exp.Wsp() {
+				t.Logf("[%d] Received %q; expected %q", i, prog.Instructions[i].Wsp(), exp.Wsp())
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestAssembleErrors(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Input  string
+		Errors []string
+	}{
+		{"Unknown", "push 1\nfoo", []string{"line 2:1: unknown instruction \"foo\""}},
+		{"Operands", "push\n  add 1\npush 1 2", []string{
+			"line 1:1: missing operand for push",
+			"line 2:7: unexpected operand for add",
+			"line 3:8: unexpected \"2\"",
+		}},
+		{"Labels", "label a\nlabel a\njump b\nlabel 1x", []string{
+			"line 2:7: duplicate label \"a\", first defined at line 1:7",
+			"line 4:7: invalid label name \"1x\"",
+			"line 3:6: undefined label \"b\"",
+		}},
+	}
+
+	for _, tst := range tests {
+		t.Log(tst.Name)
+		_, err := NewAssembler(strings.NewReader(tst.Input), "").Assemble()
+		list, ok := err.(ErrorList)
+		if !ok {
+			t.Logf("Expected an ErrorList, received %v", err)
+			t.Fail()
+			continue
+		}
+
+		if len(list) != len(tst.Errors) {
+			t.Logf("Received %d errors; expected %d: %v", len(list), len(tst.Errors), list)
+			t.Fail()
+			continue
+		}
+
+		for i, exp := range tst.Errors {
+			if list[i].Error() != exp {
+				t.Logf("Received %q; expected %q", list[i].Error(), exp)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestMacros(t *testing.T) {
+	src := `
+.macro inc reg
+	push \reg
+	push 1
+	add
+.endm
+
+.macro abs
+	duplicate
+	jumpminus neg\@
+	jump done\@
+	label neg\@
+	push -1
+	multiply
+	label done\@
+.endm
+
+.macro twice a, b
+	inc \a
+	abs
+	inc \b
+.endm
+
+twice 1, -5
+abs
+`
+	prog, err := Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	asm := []string{}
+	for _, i := range prog.Instructions {
+		asm = append(asm, i.Asm())
+	}
+
+	expected := "push 1,push 1,add," +
+		"duplicate,jumpminus s,jump t,label s,push -1,multiply,label t," +
+		"push -5,push 1,add," +
+		"duplicate,jumpminus ss,jump st,label ss,push -1,multiply,label st"
+	if strings.Join(asm, ",") != expected {
+		t.Fatalf("Unexpected output\n Rec: %s\n Exp: %s", strings.Join(asm, ","), expected)
+	}
+
+	if prog.Positions[0].Line != 3 || prog.Positions[0].Parent == nil {
+		t.Fatalf("Unexpected position for first instruction: %s", prog.Positions[0])
+	}
+}
+
+func TestMacroErrors(t *testing.T) {
+	tests := []struct {
+		Name   string
+		Input  string
+		Errors []string
+	}{
+		{"Body error", ".macro m\npush x\n.endm\nm", []string{
+			"line 2:6: undefined constant \"x\"\n\tin macro m at line 4:1",
+		}},
+		{"Nested", ".macro inner n\npush \\n\nfoo\n.endm\n.macro outer\ninner 1\n.endm\nouter", []string{
+			"line 3:1: unknown instruction \"foo\"\n\tin macro inner at line 6:1\n\tin macro outer at line 8:1",
+		}},
+		{"Arguments", ".macro m a\n.endm\nm\nm 1, 2", []string{
+			"line 3:1: macro m takes 1 arguments, 0 given",
+			"line 4:1: macro m takes 1 arguments, 2 given",
+		}},
+		{"Recursive", ".macro m\nm\n.endm\nm", []string{
+			"line 2:1: macro m nested too deeply; is it recursive?\n\tin macro m at line 2:1 (63 times)\n\tin macro m at line 4:1",
+		}},
+		{"Definitions", ".macro push\n.endm\n.macro m\n.endm\n.macro m\n.endm\n.endm\n.macro x", []string{
+			"line 1:8: macro \"push\" has the same name as an instruction",
+			"line 5:8: macro \"m\" already defined at line 3:1",
+			"line 7:1: .endm without .macro",
+			"line 8:1: missing .endm for .macro",
+		}},
+	}
+
+	for _, tst := range tests {
+		t.Log(tst.Name)
+		_, err := Assemble(strings.NewReader(tst.Input))
+		list, ok := err.(ErrorList)
+		if !ok {
+			t.Logf("Expected an ErrorList, received %v", err)
+			t.Fail()
+			continue
+		}
+
+		if len(list) != len(tst.Errors) {
+			t.Logf("Received %d errors; expected %d: %v", len(list), len(tst.Errors), list)
+			t.Fail()
+			continue
+		}
+
+		for i, exp := range tst.Errors {
+			if list[i].Error() != exp {
+				t.Logf("Received %q; expected %q", list[i].Error(), exp)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, src := range files {
+		path := filepath.Join(dir, name)
+		err := os.MkdirAll(filepath.Dir(path), 0755)
+		if err == nil {
+			err = os.WriteFile(path, []byte(src), 0644)
+		}
+		if err != nil {
+			t.Fatalf("Unable to write %s: %s", name, err)
+		}
+	}
+	return dir
+}
+
+func TestInclude(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.wsa":       ".include \"lib.wsa\"\n.include \"lib.wsa\"\n.include \"sub/print.wsa\"\nprint 1\nstop",
+		"lib.wsa":        ".once\npush 2",
+		"sub/print.wsa":  ".include \"macros.wsa\"",
+		"inc/macros.wsa": ".macro print n\npush \\n\nprintnumber\n.endm",
+	})
+
+	main := filepath.Join(dir, "main.wsa")
+	src, _ := os.ReadFile(main)
+	a := NewAssembler(strings.NewReader(string(src)), main)
+	a.IncludePaths = []string{filepath.Join(dir, "inc")}
+
+	prog, err := a.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	asm := []string{}
+	for _, i := range prog.Instructions {
+		asm = append(asm, i.Asm())
+	}
+
+	expected := "push 2,push 1,printnumber,stop"
+	if strings.Join(asm, ",") != expected {
+		t.Fatalf("Unexpected output\n Rec: %s\n Exp: %s", strings.Join(asm, ","), expected)
+	}
+
+	if prog.Positions[0].Filename != filepath.Join(dir, "lib.wsa") {
+		t.Fatalf("Unexpected position for first instruction: %s", prog.Positions[0])
+	}
+}
+
+func TestIncludeErrors(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"main.wsa": ".include \"a.wsa\"\n.include \"missing.wsa\"\n.include a.wsa",
+		"a.wsa":    "push 1\n.include \"b.wsa\"",
+		"b.wsa":    "foo\n.include \"a.wsa\"",
+	})
+
+	main := filepath.Join(dir, "main.wsa")
+	src, _ := os.ReadFile(main)
+	_, err := NewAssembler(strings.NewReader(string(src)), main).Assemble()
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, received %v", err)
+	}
+
+	expected := []string{
+		dir + "/b.wsa:2:1: include cycle: a.wsa -> b.wsa -> a.wsa\n\tincluded from " + dir + "/a.wsa:2:1\n\tincluded from " + main + ":1:1",
+		main + ":2:10: unable to find included file \"missing.wsa\"",
+		main + ":3:10: expected a quoted filename for .include",
+		dir + "/b.wsa:1:1: unknown instruction \"foo\"\n\tincluded from " + dir + "/a.wsa:2:1\n\tincluded from " + main + ":1:1",
+	}
+
+	if len(list) != len(expected) {
+		t.Fatalf("Received %d errors; expected %d: %v", len(list), len(expected), list)
+	}
+
+	for i, exp := range expected {
+		if list[i].Error() != exp {
+			t.Logf("Received %q; expected %q", list[i].Error(), exp)
+			t.Fail()
+		}
+	}
+}
+
+func TestConditionals(t *testing.T) {
+	src := `
+.ifdef DEBUG
+	push TRACE
+	printnumber
+.else
+	.define VALUE 2
+.endif
+
+.ifndef RELEASE
+	.ifdef VALUE
+		push VALUE
+	.else
+		push 3
+	.endif
+.endif
+
+.undef VALUE
+.ifdef VALUE
+	push 4
+.endif
+`
+	tests := []struct {
+		Defines  map[string]string
+		Expected string
+	}{
+		{nil, "push 2"},
+		{map[string]string{"DEBUG": "", "TRACE": "7"}, "push 7,printnumber,push 3"},
+		{map[string]string{"RELEASE": "1"}, ""},
+	}
+
+	for _, tst := range tests {
+		a := NewAssembler(strings.NewReader(src), "")
+		for k, v := range tst.Defines {
+			a.Defines[k] = v
+		}
+
+		prog, err := a.Assemble()
+		if err != nil {
+			t.Logf("Assemble() error with %v: %s", tst.Defines, err)
+			t.Fail()
+			continue
+		}
+
+		asm := []string{}
+		for _, i := range prog.Instructions {
+			asm = append(asm, i.Asm())
+		}
+
+		if strings.Join(asm, ",") != tst.Expected {
+			t.Logf("Unexpected output with %v\n Rec: %s\n Exp: %s", tst.Defines, strings.Join(asm, ","), tst.Expected)
+			t.Fail()
+		}
+	}
+
+	// quoted text isn't replaced
+	_, err := Assemble(strings.NewReader(".define VALUE 1\npush \"VALUE\""))
+	if err == nil || !strings.Contains(err.Error(), `unexpected '"'`) {
+		t.Logf("Unexpected error: %v", err)
+		t.Fail()
+	}
+}
+
+func TestConditionalErrors(t *testing.T) {
+	src := ".else\n.endif\n.ifdef\n.endif\n.ifdef A\n.else\n.else\n.endif x\n.ifndef B"
+	expected := []string{
+		"line 1:1: .else without .ifdef or .ifndef",
+		"line 2:1: .endif without .ifdef or .ifndef",
+		"line 3:7: expected a name for .ifdef",
+		"line 7:1: duplicate .else for .ifdef at line 5:1",
+		"line 8:8: unexpected operand for .endif",
+		"line 9:1: missing .endif for .ifndef",
+	}
+
+	_, err := Assemble(strings.NewReader(src))
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, received %v", err)
+	}
+
+	if len(list) != len(expected) {
+		t.Fatalf("Received %d errors; expected %d: %v", len(list), len(expected), list)
+	}
+
+	for i, exp := range expected {
+		if list[i].Error() != exp {
+			t.Logf("Received %q; expected %q", list[i].Error(), exp)
+			t.Fail()
+		}
+	}
+}
+
+func TestLocalLabels(t *testing.T) {
+	src := `
+print:
+	jump .loop
+.loop:
+	jump 1f
+1:
+	jump 1b
+	jump .loop
+read:
+.loop:
+1:
+	jump 1b
+	jump 1f
+	jump print.loop
+1:
+`
+	prog, err := Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	names := []string{}
+	for _, sym := range prog.Symbols {
+		names = append(names, sym.Name)
+	}
+
+	// numeric labels only have internal names
+	expected := "print,print.loop,read,read.loop"
+	if strings.Join(names, ",") != expected {
+		t.Fatalf("Unexpected symbols\n Rec: %s\n Exp: %s", strings.Join(names, ","), expected)
+	}
+
+	names = []string{}
+	for _, sym := range prog.NumericLabels {
+		names = append(names, sym.Name+" "+inst.DecodeLabel(sym.Encoding))
+	}
+
+	expected = "1 ss,1 tt,1 sss"
+	if strings.Join(names, ",") != expected {
+		t.Fatalf("Unexpected numeric labels\n Rec: %s\n Exp: %s", strings.Join(names, ","), expected)
+	}
+
+	asm := []string{}
+	for _, i := range prog.Instructions {
+		asm = append(asm, i.Asm())
+	}
+
+	// print, print.loop, 1@1, read, read.loop, 1@2, 1@3
+	// s,     t,          ss,  st,   ts,        tt,  sss
+	expected = "label s,jump t,label t,jump ss,label ss,jump ss,jump t," +
+		"label st,label ts,label tt,jump tt,jump sss,jump t,label sss"
+	if strings.Join(asm, ",") != expected {
+		t.Fatalf("Unexpected output\n Rec: %s\n Exp: %s", strings.Join(asm, ","), expected)
+	}
+
+	src = ".loop:\nlabel a\nlabel .b\nlabel .b\njump 1b\njump 2f\n1:\njump .c\n1f:\na.b:\nlabel .b.c"
+	errs := []string{
+		"line 1:1: local label \".loop\" is not after a global label",
+		"line 9:1: numeric label \"1f\" is a reference; define it as \"1\"",
+		"line 10:1: label \"a.b\" can only have a dot at the start",
+		"line 11:7: label \".b.c\" can only have a dot at the start",
+		"line 4:7: duplicate label \".b\", first defined at line 3:7",
+		"line 5:6: undefined label \"1b\"",
+		"line 6:6: undefined label \"2f\"",
+		"line 8:6: undefined label \".c\"",
+	}
+
+	_, err = Assemble(strings.NewReader(src))
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, received %v", err)
+	}
+
+	if len(list) != len(errs) {
+		t.Fatalf("Received %d errors; expected %d: %v", len(list), len(errs), list)
+	}
+
+	for i, exp := range errs {
+		if list[i].Error() != exp {
+			t.Logf("Received %q; expected %q", list[i].Error(), exp)
+			t.Fail()
+		}
+	}
+}
+
+func TestDialects(t *testing.T) {
+	short := "main:\n\tpush 3\n\tdup\n\touTN\n\tjz main\n\tretrieve 5\n\tmul\n\tend"
+	native := "label main\n\tpush 3\n\tduplicate\n\tprintnumber\n\tjumpzero main\n\tpush 5\n\tload\n\tmultiply\n\tstop"
+
+	a := NewAssembler(strings.NewReader(short), "")
+	a.Dialect = inst.Short
+	prog, err := a.Assemble()
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	expected, err := Assemble(strings.NewReader(native))
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	if prog.Wsp() != expected.Wsp() {
+		t.Fatalf("Unexpected output\n Rec: %q\n Exp: %q", prog.Wsp(), expected.Wsp())
+	}
+
+	asm := []string{}
+	for _, i := range prog.Instructions {
+		asm = append(asm, inst.Short.Asm(i))
+	}
+
+	exp := "s:,push 3,dup,outn,jz s,push 5,retrieve,mul,end"
+	if strings.Join(asm, ",") != exp {
+		t.Fatalf("Unexpected assembly\n Rec: %s\n Exp: %s", strings.Join(asm, ","), exp)
+	}
+
+	// short mnemonics aren't instructions in the native dialect
+	_, err = Assemble(strings.NewReader(short))
+	if err == nil || !strings.Contains(err.Error(), `unknown instruction "dup"`) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// or macro names in the short dialect
+	a = NewAssembler(strings.NewReader(".macro dup\n.endm"), "")
+	a.Dialect = inst.Short
+	_, err = a.Assemble()
+	if err == nil || !strings.Contains(err.Error(), `macro "dup" has the same name as an instruction`) {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if _, err := inst.LookupDialect("Short"); err != nil {
+		t.Fatalf("LookupDialect() error: %s", err)
+	}
+}
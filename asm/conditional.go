@@ -0,0 +1,139 @@
+package asm
+
+import (
+	"strings"
+)
+
+// conditional is an open .ifdef or .ifndef block.
+type conditional struct {
+	pos       Position
+	directive string
+	parent    bool // lines outside of the block are kept
+	taken     bool // the condition was true
+	inElse    bool
+}
+
+func (c *conditional) active() bool {
+	return c.parent && c.taken != c.inElse
+}
+
+func activeConditional(conds []*conditional) bool {
+	return len(conds) == 0 || conds[len(conds)-1].active()
+}
+
+// conditional handles the conditional assembly directives and returns true
+// if stmt was one of them.
+func (a *Assembler) conditional(conds *[]*conditional, stmt statement) bool {
+	switch stmt.mnemonic {
+	case ".ifdef", ".ifndef":
+		c := &conditional{
+			pos:       stmt.pos,
+			directive: stmt.mnemonic,
+			parent:    activeConditional(*conds),
+		}
+
+		if c.parent {
+			if !isLabelName(stmt.operand) {
+				a.errorf(stmt.opPos, "expected a name for %s", stmt.mnemonic)
+			}
+			_, defined := a.Defines[stmt.operand]
+			c.taken = defined == (stmt.mnemonic == ".ifdef")
+		}
+
+		*conds = append(*conds, c)
+
+	case ".else":
+		if len(*conds) == 0 {
+			a.errorf(stmt.pos, ".else without .ifdef or .ifndef")
+			return true
+		}
+
+		c := (*conds)[len(*conds)-1]
+		if c.inElse {
+			a.errorf(stmt.pos, "duplicate .else for %s at %s", c.directive, c.pos)
+		}
+		c.inElse = true
+
+	case ".endif":
+		if len(*conds) == 0 {
+			a.errorf(stmt.pos, ".endif without .ifdef or .ifndef")
+			return true
+		}
+		*conds = (*conds)[:len(*conds)-1]
+
+	default:
+		return false
+	}
+
+	if (stmt.mnemonic == ".else" || stmt.mnemonic == ".endif") && stmt.operand != "" {
+		a.errorf(stmt.opPos, "unexpected operand for %s", stmt.mnemonic)
+	}
+	return true
+}
+
+// define handles .define and .undef.
+func (a *Assembler) define(stmt statement) {
+	name := stmt.operand
+	value := ""
+	if end := strings.IndexAny(name, " \t"); end != -1 {
+		name, value = name[:end], strings.TrimSpace(name[end:])
+	}
+
+	if !isLabelName(name) {
+		a.errorf(stmt.opPos, "expected a name for %s", stmt.mnemonic)
+		return
+	}
+
+	if stmt.mnemonic == ".undef" {
+		if value != "" {
+			a.errorf(stmt.opPos, "unexpected value for .undef")
+		}
+		delete(a.Defines, name)
+		return
+	}
+
+	a.Defines[name] = value
+}
+
+// applyDefines replaces every name in the operand that has been defined with
+// its value.  Quoted text is left alone.
+func (a *Assembler) applyDefines(l line, stmt statement) line {
+	if len(a.Defines) == 0 || stmt.operand == "" {
+		return l
+	}
+
+	sb := &strings.Builder{}
+	op := stmt.operand
+	var quote byte
+	escaped := false
+
+	for i := 0; i < len(op); i++ {
+		c := op[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '\'' || c == '"':
+			quote = c
+		case isIdentByte(c) && (i == 0 || !isIdentByte(op[i-1])):
+			end := i
+			for end < len(op) && isIdentByte(op[end]) {
+				end++
+			}
+
+			if value, ok := a.Defines[op[i:end]]; ok {
+				sb.WriteString(value)
+				i = end - 1
+				continue
+			}
+		}
+		sb.WriteByte(c)
+	}
+
+	l.text = l.text[:len(l.text)-len(op)] + sb.String()
+	return l
+}
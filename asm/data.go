@@ -0,0 +1,209 @@
+package asm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// region is a block of heap that is set by a data directive before the
+// program starts.
+type region struct {
+	pos       Position
+	directive string
+	addr      int64
+	size      int64
+	values    []int64 // nil for variables
+}
+
+func (r *region) end() int64 {
+	return r.addr + r.size - 1
+}
+
+func (r *region) String() string {
+	return fmt.Sprintf("%s at %s (%d-%d)", r.directive, r.pos, r.addr, r.end())
+}
+
+// dataDirectives parse the operand of each data directive into a region.
+var dataDirectives = map[string]func(a *Assembler, stmt statement) *region{
+	".data":  (*Assembler).dataString,
+	".words": (*Assembler).dataWords,
+	".zero":  (*Assembler).dataZero,
+}
+
+// splitAddress splits the address off of the start of a data directive's
+// operand.  The address is an expression that ends at a comma, at the quote
+// that starts a string, or where the next value starts, as in "BUF + 4 1".
+// Also returns the offset of the rest of the operand.
+func splitAddress(operand string) (string, string, int) {
+	end := len(operand)
+	for i := 0; i < len(operand); i++ {
+		if operand[i] == '\'' {
+			// skip over characters, which can be a comma or quote
+			for i++; i < len(operand) && operand[i] != '\''; i++ {
+				if operand[i] == '\\' {
+					i++
+				}
+			}
+		} else if operand[i] == ',' || operand[i] == '"' {
+			end = i
+			break
+		}
+	}
+
+	// a value right after another value starts the next operand
+	if tokens, err := tokenize(operand[:end]); err == nil {
+		depth := 0
+		for i := 1; i < len(tokens); i++ {
+			prev, t := tokens[i-1], tokens[i]
+			switch {
+			case prev.text == "(":
+				depth++
+			case prev.text == ")":
+				depth--
+			}
+
+			value := prev.kind != tokOperator || prev.text == ")"
+			if depth <= 0 && value && (t.kind == tokNumber || t.kind == tokChar || t.kind == tokName || t.text == "(") {
+				end = t.offset
+				break
+			}
+		}
+	}
+
+	rest := strings.TrimLeft(operand[end:], " \t")
+	rest = strings.TrimLeft(strings.TrimPrefix(rest, ","), " \t")
+	return strings.TrimRight(operand[:end], " \t"), rest, len(operand) - len(rest)
+}
+
+// dataAddress returns a new region with the address from the operand, the
+// rest of the operand, and its offset.
+func (a *Assembler) dataAddress(stmt statement) (*region, string, int) {
+	addr, rest, offset := splitAddress(stmt.operand)
+	if addr == "" {
+		a.errorf(stmt.opPos, "missing address for %s", stmt.mnemonic)
+		return nil, "", 0
+	}
+
+	n, ok := a.evaluate(addr, stmt.opPos)
+	if !ok {
+		return nil, "", 0
+	}
+
+	return &region{pos: stmt.pos, directive: stmt.mnemonic, addr: n}, rest, offset
+}
+
+// dataString stores a string followed by a zero.
+func (a *Assembler) dataString(stmt statement) *region {
+	r, rest, offset := a.dataAddress(stmt)
+	if r == nil {
+		return nil
+	}
+
+	str, err := parseString(rest)
+	if err != nil {
+		a.errorf(stmt.at(offset), "%s for .data", err)
+		return nil
+	}
+
+	for _, c := range str {
+		r.values = append(r.values, int64(c))
+	}
+	r.values = append(r.values, 0)
+	return r
+}
+
+// dataWords stores a list of numbers separated by commas.
+func (a *Assembler) dataWords(stmt statement) *region {
+	r, rest, offset := a.dataAddress(stmt)
+	if r == nil {
+		return nil
+	}
+
+	if rest == "" {
+		a.errorf(stmt.at(offset), "missing values for .words")
+		return nil
+	}
+
+	for _, arg := range splitArgs(rest) {
+		idx := strings.Index(stmt.operand[offset:], arg)
+		n, ok := a.evaluate(arg, stmt.at(offset+idx))
+		if !ok {
+			return nil
+		}
+		r.values = append(r.values, n)
+		offset += idx + len(arg)
+	}
+	return r
+}
+
+// dataZero stores N zeros.
+func (a *Assembler) dataZero(stmt statement) *region {
+	r, rest, offset := a.dataAddress(stmt)
+	if r == nil {
+		return nil
+	}
+
+	n, ok := a.evaluate(rest, stmt.at(offset))
+	if !ok {
+		return nil
+	}
+
+	if n < 1 {
+		a.errorf(stmt.at(offset), "expected a positive count for .zero")
+		return nil
+	}
+
+	r.values = make([]int64, n)
+	return r
+}
+
+// addRegion records a region, checking that it doesn't wrap around.
+func (a *Assembler) addRegion(r *region) {
+	if r.values != nil {
+		r.size = int64(len(r.values))
+	}
+
+	if r.end() < r.addr {
+		a.errorf(r.pos, "%s runs past the largest address", r.directive)
+		return
+	}
+	a.regions = append(a.regions, r)
+}
+
+// dataPrologue checks for overlapping regions and returns the instructions
+// to store them in the heap.
+func (a *Assembler) dataPrologue() ([]inst.Instruction, []Position) {
+	sorted := make([]*region, len(a.regions))
+	copy(sorted, a.regions)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].addr < sorted[j].addr
+	})
+
+	var last *region // region reaching the highest address so far
+	for _, r := range sorted {
+		if last != nil && r.addr <= last.end() {
+			a.errorf(r.pos, "%s overlaps %s", r, last)
+		}
+		if last == nil || r.end() > last.end() {
+			last = r
+		}
+	}
+
+	lst := []inst.Instruction{}
+	pos := []Position{}
+	for _, r := range a.regions {
+		for i, v := range r.values {
+			lst = append(lst,
+				&inst.Push{Value: r.addr + int64(i)},
+				&inst.Push{Value: v},
+				&inst.Store{},
+			)
+			pos = append(pos, r.pos, r.pos, r.pos)
+		}
+	}
+
+	return lst, pos
+}
@@ -0,0 +1,130 @@
+package asm
+
+import (
+	"strings"
+	"testing"
+
+	ws "github.com/zorchenhimer/whitespace"
+)
+
+func TestDataDirectives(t *testing.T) {
+	src := `
+.data 100 "Hi"
+.words 0x10, 1, -2
+.zero 200 2
+
+# print the string at 100
+	push 100
+label loop
+	duplicate
+	load
+	duplicate
+	jumpzero done
+	printchar
+	push 1
+	add
+	jump loop
+label done
+	push 17
+	load
+	printnumber
+	push 201
+	load
+	printnumber
+	stop
+`
+	prog, err := Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	// three instructions for each of the 7 values
+	if prog.Instructions[20].Asm() != "store" || prog.Instructions[21].Asm() != "push 100" {
+		t.Fatalf("Unexpected prologue: %s, %s", prog.Instructions[20].Asm(), prog.Instructions[21].Asm())
+	}
+
+	if prog.Positions[0].Line != 2 || prog.Positions[21].Line != 7 {
+		t.Fatalf("Unexpected positions: %s, %s", prog.Positions[0], prog.Positions[21])
+	}
+
+	e, err := ws.NewEngine(strings.NewReader(prog.Wsp()))
+	if err != nil {
+		t.Fatalf("Engine creation fail: %s", err)
+	}
+
+	out := &strings.Builder{}
+	err = e.Run(nil, out)
+	if err != nil {
+		t.Fatalf("Run fail: %s", err)
+	}
+
+	if out.String() != "Hi-20" {
+		t.Fatalf("Unexpected output.\n Rec: %q\n Exp: %q", out.String(), "Hi-20")
+	}
+}
+
+func TestDataErrors(t *testing.T) {
+	src := ".data 10 \"abc\"\n.words 13 1\n.zero 5 6\n.words 20\n.zero 30 0\n.data x \"a\"\n.data 40 abc"
+	expected := []string{
+		"line 4:10: missing values for .words",
+		"line 5:10: expected a positive count for .zero",
+		"line 6:7: undefined constant \"x\"",
+		"line 7:10: expected a string in double quotes for .data",
+		"line 1:1: .data at line 1:1 (10-13) overlaps .zero at line 3:1 (5-10)",
+		"line 2:1: .words at line 2:1 (13-13) overlaps .data at line 1:1 (10-13)",
+	}
+
+	_, err := Assemble(strings.NewReader(src))
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, received %v", err)
+	}
+
+	if len(list) != len(expected) {
+		t.Fatalf("Received %d errors; expected %d: %v", len(list), len(expected), list)
+	}
+
+	for i, exp := range expected {
+		if list[i].Error() != exp {
+			t.Logf("Received %q; expected %q", list[i].Error(), exp)
+			t.Fail()
+		}
+	}
+}
+
+func TestSplitAddress(t *testing.T) {
+	tests := []struct {
+		operand string
+		addr    string
+		rest    string
+	}{
+		{`100 "Hi"`, "100", `"Hi"`},
+		{`BUF + 4 "x"`, "BUF + 4", `"x"`},
+		{`BUF+4"x"`, "BUF+4", `"x"`},
+		{`0x10, 1, -2`, "0x10", "1, -2"},
+		{`(BUF + 1) * 2 1, 2`, "(BUF + 1) * 2", "1, 2"},
+		{`',' + 1, 2`, "',' + 1", "2"},
+		{`BUF + 4 LEN - 1`, "BUF + 4", "LEN - 1"},
+		{`200 2`, "200", "2"},
+		{`40 abc`, "40", "abc"},
+		{`"x"`, "", `"x"`},
+	}
+
+	for _, tt := range tests {
+		addr, rest, offset := splitAddress(tt.operand)
+		if addr != tt.addr || rest != tt.rest || tt.operand[offset:] != rest {
+			t.Logf("splitAddress(%q) = %q, %q, %d; expected %q, %q", tt.operand, addr, rest, offset, tt.addr, tt.rest)
+			t.Fail()
+		}
+	}
+
+	src := ".equ BUF 10\n.data BUF + 4 \"x\"\n\tpush 14\n\tload\n\tprintchar\n\tstop\n"
+	prog, err := Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	if prog.Instructions[0].Asm() != "push 14" {
+		t.Fatalf("Unexpected address: %s", prog.Instructions[0].Asm())
+	}
+}
@@ -0,0 +1,138 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Position in an assembly source file.  Lines and columns start at one.  A
+// column of zero means the whole line.
+type Position struct {
+	Filename string
+	Line     int
+	Column   int
+
+	// Set if the line was produced by expanding something else, like a
+	// macro.
+	Parent *Expansion
+}
+
+// Expansion is where a line came from when it isn't directly in the source
+// being assembled.
+type Expansion struct {
+	Macro string // name of the expanded macro, empty for includes
+	Pos   Position
+}
+
+func (e *Expansion) String() string {
+	if e.Macro == "" {
+		return fmt.Sprintf("included from %s", e.Pos)
+	}
+	return fmt.Sprintf("in macro %s at %s", e.Macro, e.Pos)
+}
+
+// Trace returns each expansion leading to the position, innermost first.
+func (p Position) Trace() []*Expansion {
+	trace := []*Expansion{}
+	for e := p.Parent; e != nil; e = e.Pos.Parent {
+		trace = append(trace, e)
+	}
+	return trace
+}
+
+func (p Position) String() string {
+	s := p.Filename
+	if s == "" {
+		s = "line "
+	} else {
+		s += ":"
+	}
+
+	s += fmt.Sprint(p.Line)
+	if p.Column > 0 {
+		s += fmt.Sprintf(":%d", p.Column)
+	}
+	return s
+}
+
+// Error is a problem with the source at a given position.
+type Error struct {
+	Pos Position
+	Msg string
+
+	// The line the error is on, if it's known.
+	Source string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg) + e.trace()
+}
+
+// Detail is like Error() with the source line added below the message and a
+// caret under the column.
+func (e *Error) Detail() string {
+	s := fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+	if e.Source == "" {
+		return s + e.trace()
+	}
+
+	s += "\n    " + e.Source
+	if e.Pos.Column > 0 {
+		// keep tabs so the caret lines up
+		col := e.Pos.Column - 1
+		if col > len(e.Source) {
+			col = len(e.Source)
+		}
+
+		pad := strings.Map(func(r rune) rune {
+			if r == '\t' {
+				return r
+			}
+			return ' '
+		}, e.Source[:col])
+		s += "\n    " + pad + "^"
+	}
+	return s + e.trace()
+}
+
+// trace returns a line for each expansion leading to the error.
+func (e *Error) trace() string {
+	s := ""
+
+	// Collapse repeats so recursion doesn't bury the outermost expansion.
+	trace := e.Pos.Trace()
+	for i := 0; i < len(trace); i++ {
+		s += "\n\t" + trace[i].String()
+
+		n := 1
+		for i+n < len(trace) && trace[i+n].String() == trace[i].String() {
+			n++
+		}
+		if n > 1 {
+			s += fmt.Sprintf(" (%d times)", n)
+			i += n - 1
+		}
+	}
+	return s
+}
+
+// ErrorList is every error found while assembling.
+type ErrorList []*Error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	}
+	return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+}
+
+// Err returns nil if the list is empty.
+func (l ErrorList) Err() error {
+	if len(l) == 0 {
+		return nil
+	}
+	return l
+}
@@ -0,0 +1,360 @@
+package asm
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// evaluate returns the value of an expression operand that starts at pos.
+// Errors are reported and return false.
+func (a *Assembler) evaluate(expr string, pos Position) (int64, bool) {
+	v, err := evaluate(expr, a.constants)
+	if err != nil {
+		var ee *exprError
+		if errors.As(err, &ee) {
+			pos.Column += ee.offset
+		}
+		a.errorf(pos, "%s", err)
+		return 0, false
+	}
+	return v, true
+}
+
+// defineConstant handles .equ NAME VALUE.
+func (a *Assembler) defineConstant(stmt statement) {
+	name := stmt.operand
+	value := ""
+	offset := len(name)
+	if end := strings.IndexAny(name, " \t"); end != -1 {
+		value = strings.TrimLeft(name[end:], " \t")
+		offset = len(name) - len(value)
+		name = name[:end]
+	}
+
+	if !isLabelName(name) {
+		a.errorf(stmt.opPos, "expected a name for .equ")
+		return
+	}
+
+	if pos, exist := a.constantPos[name]; exist {
+		a.errorf(stmt.opPos, "constant %q already defined at %s", name, pos)
+		return
+	}
+
+	v, ok := a.evaluate(value, stmt.at(offset))
+	if !ok {
+		return
+	}
+
+	a.constants[name] = v
+	a.constantPos[name] = stmt.pos
+}
+
+// exprError is an error at an offset into an expression.
+type exprError struct {
+	offset int
+	msg    string
+}
+
+func (e *exprError) Error() string {
+	return e.msg
+}
+
+type tokenKind int
+
+const (
+	tokEnd tokenKind = iota
+	tokNumber
+	tokChar
+	tokName
+	tokOperator
+)
+
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+// tokenize splits an expression into numbers, characters, names, and
+// operators.
+func tokenize(expr string) ([]token, error) {
+	tokens := []token{}
+
+	for i := 0; i < len(expr); {
+		c := expr[i]
+		start := i
+
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+			continue
+
+		case c >= '0' && c <= '9':
+			for i < len(expr) && isIdentByte(expr[i]) && expr[i] != '.' {
+				i++
+			}
+			tokens = append(tokens, token{tokNumber, expr[start:i], start})
+
+		case c == '\'':
+			i++
+			for i < len(expr) && expr[i] != '\'' {
+				if expr[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(expr) {
+				return nil, &exprError{start, "unterminated character"}
+			}
+			i++
+			tokens = append(tokens, token{tokChar, expr[start:i], start})
+
+		case isIdentByte(c):
+			for i < len(expr) && isIdentByte(expr[i]) {
+				i++
+			}
+			tokens = append(tokens, token{tokName, expr[start:i], start})
+
+		case strings.HasPrefix(expr[i:], "<<"), strings.HasPrefix(expr[i:], ">>"):
+			i += 2
+			tokens = append(tokens, token{tokOperator, expr[start:i], start})
+
+		case strings.IndexByte("+-*/%()", c) != -1:
+			i++
+			tokens = append(tokens, token{tokOperator, expr[start:i], start})
+
+		default:
+			return nil, &exprError{start, fmt.Sprintf("unexpected %q", c)}
+		}
+	}
+
+	return append(tokens, token{tokEnd, "", len(expr)}), nil
+}
+
+// evaluator is a recursive descent parser that evaluates as it goes.
+// Operators have the same precedence as in C:
+//
+//	unary - +
+//	* / %
+//	+ -
+//	<< >>
+type evaluator struct {
+	tokens    []token
+	pos       int
+	constants map[string]int64
+}
+
+// evaluate returns the value of a constant expression.  Names are looked up
+// in constants.
+func evaluate(expr string, constants map[string]int64) (int64, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return 0, err
+	}
+
+	if tokens[0].kind == tokEnd {
+		return 0, &exprError{0, "missing value"}
+	}
+
+	e := &evaluator{tokens: tokens, constants: constants}
+	v, err := e.shift()
+	if err != nil {
+		return 0, err
+	}
+
+	if t := e.peek(); t.kind != tokEnd {
+		return 0, &exprError{t.offset, fmt.Sprintf("unexpected %q", t.text)}
+	}
+	return v, nil
+}
+
+func (e *evaluator) peek() token {
+	return e.tokens[e.pos]
+}
+
+func (e *evaluator) next() token {
+	t := e.tokens[e.pos]
+	if t.kind != tokEnd {
+		e.pos++
+	}
+	return t
+}
+
+func (e *evaluator) isOperator(ops ...string) bool {
+	t := e.peek()
+	if t.kind != tokOperator {
+		return false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (e *evaluator) shift() (int64, error) {
+	v, err := e.sum()
+	for err == nil && e.isOperator("<<", ">>") {
+		op := e.next()
+
+		var n int64
+		n, err = e.sum()
+		if err != nil {
+			break
+		}
+
+		if n < 0 || n > 63 {
+			return 0, &exprError{op.offset, fmt.Sprintf("shift by %d is out of range", n)}
+		}
+
+		if op.text == ">>" {
+			v >>= n
+		} else {
+			shifted := v << n
+			if shifted>>n != v {
+				return 0, &exprError{op.offset, "overflow"}
+			}
+			v = shifted
+		}
+	}
+	return v, err
+}
+
+func (e *evaluator) sum() (int64, error) {
+	v, err := e.product()
+	for err == nil && e.isOperator("+", "-") {
+		op := e.next()
+
+		var n int64
+		n, err = e.product()
+		if err != nil {
+			break
+		}
+
+		if op.text == "-" {
+			if (n < 0 && v > math.MaxInt64+n) || (n > 0 && v < math.MinInt64+n) {
+				return 0, &exprError{op.offset, "overflow"}
+			}
+			v -= n
+		} else {
+			if (n > 0 && v > math.MaxInt64-n) || (n < 0 && v < math.MinInt64-n) {
+				return 0, &exprError{op.offset, "overflow"}
+			}
+			v += n
+		}
+	}
+	return v, err
+}
+
+func (e *evaluator) product() (int64, error) {
+	v, err := e.unary()
+	for err == nil && e.isOperator("*", "/", "%") {
+		op := e.next()
+
+		var n int64
+		n, err = e.unary()
+		if err != nil {
+			break
+		}
+
+		switch op.text {
+		case "*":
+			if v != 0 && n != 0 {
+				p := v * n
+				if p/n != v || (v == -1 && n == math.MinInt64) || (n == -1 && v == math.MinInt64) {
+					return 0, &exprError{op.offset, "overflow"}
+				}
+			}
+			v *= n
+		case "/", "%":
+			if n == 0 {
+				return 0, &exprError{op.offset, "division by zero"}
+			}
+			if n == -1 && v == math.MinInt64 {
+				return 0, &exprError{op.offset, "overflow"}
+			}
+			if op.text == "/" {
+				v /= n
+			} else {
+				v %= n
+			}
+		}
+	}
+	return v, err
+}
+
+func (e *evaluator) unary() (int64, error) {
+	if !e.isOperator("-", "+") {
+		return e.primary()
+	}
+
+	op := e.next()
+
+	// A negative literal can be one larger than a positive one.
+	if t := e.peek(); op.text == "-" && t.kind == tokNumber {
+		e.next()
+		n, err := parseNumber("-" + t.text)
+		if err != nil {
+			return 0, &exprError{t.offset, err.Error()}
+		}
+		return n, nil
+	}
+
+	v, err := e.unary()
+	if err != nil {
+		return 0, err
+	}
+
+	if op.text == "-" {
+		if v == math.MinInt64 {
+			return 0, &exprError{op.offset, "overflow"}
+		}
+		v = -v
+	}
+	return v, nil
+}
+
+func (e *evaluator) primary() (int64, error) {
+	t := e.next()
+
+	switch t.kind {
+	case tokNumber, tokChar:
+		n, err := parseNumber(t.text)
+		if err != nil {
+			return 0, &exprError{t.offset, err.Error()}
+		}
+		return n, nil
+
+	case tokName:
+		v, ok := e.constants[t.text]
+		if !ok {
+			names := []string{}
+			for n := range e.constants {
+				names = append(names, n)
+			}
+			return 0, &exprError{t.offset, fmt.Sprintf("undefined constant %q%s", t.text, didYouMean(t.text, names))}
+		}
+		return v, nil
+
+	case tokOperator:
+		if t.text == "(" {
+			v, err := e.shift()
+			if err != nil {
+				return 0, err
+			}
+
+			if !e.isOperator(")") {
+				return 0, &exprError{e.peek().offset, "missing )"}
+			}
+			e.next()
+			return v, nil
+		}
+		return 0, &exprError{t.offset, fmt.Sprintf("unexpected %q", t.text)}
+	}
+
+	return 0, &exprError{t.offset, "missing value"}
+}
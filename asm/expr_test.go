@@ -0,0 +1,125 @@
+package asm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEvaluate(t *testing.T) {
+	constants := map[string]int64{"SIZE": 10, "BASE": 0x100, "max.len": 3}
+
+	tests := []struct {
+		Input  string
+		Output int64
+	}{
+		{"1 + 2 * 3", 7},
+		{"(1 + 2) * 3", 9},
+		{"1 << 4 + 1", 32},
+		{"-2 * -3", 6},
+		{"7 / 2", 3},
+		{"-7 % 3", -1},
+		{"BASE + SIZE * 2", 276},
+		{"max.len-1", 2},
+		{"'a' - 'A'", 32},
+		{"0x10 >> 2", 4},
+		{"-(SIZE)", -10},
+		{"- -1", 1},
+		{"-9223372036854775808", -9223372036854775808},
+	}
+
+	for _, tst := range tests {
+		n, err := evaluate(tst.Input, constants)
+		if err != nil {
+			t.Logf("evaluate(%q) error: %s", tst.Input, err)
+			t.Fail()
+			continue
+		}
+
+		if n != tst.Output {
+			t.Logf("evaluate(%q) = %d; expected %d", tst.Input, n, tst.Output)
+			t.Fail()
+		}
+	}
+
+	bad := []struct {
+		Input string
+		Error string
+	}{
+		{"", "missing value"},
+		{"1 +", "missing value"},
+		{"(1 + 2", "missing )"},
+		{"1 2", "unexpected \"2\""},
+		{"1 $ 2", "unexpected '$'"},
+		{"COUNT", "undefined constant \"COUNT\""},
+		{"SIZE / 0", "division by zero"},
+		{"1 % (SIZE - 10)", "division by zero"},
+		{"0x7fffffffffffffff + 1", "overflow"},
+		{"-9223372036854775808 - 1", "overflow"},
+		{"0x100000000 * 0x100000000", "overflow"},
+		{"1 << 63", "overflow"},
+		{"1 << 64", "shift by 64 is out of range"},
+		{"-(-9223372036854775808)", "overflow"},
+		{"9223372036854775808", "number out of range"},
+	}
+
+	for _, tst := range bad {
+		_, err := evaluate(tst.Input, constants)
+		if err == nil || !strings.Contains(err.Error(), tst.Error) {
+			t.Logf("evaluate(%q) error %v; expected %q", tst.Input, err, tst.Error)
+			t.Fail()
+		}
+	}
+}
+
+func TestConstants(t *testing.T) {
+	src := `.equ SIZE 4
+.equ BUF 0x100
+.equ END BUF + SIZE * 2
+.zero BUF SIZE*2
+.words END SIZE, -SIZE
+	push END - 1
+	push (SIZE + 1) % 3
+`
+	prog, err := Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	asm := []string{}
+	for _, i := range prog.Instructions {
+		asm = append(asm, i.Asm())
+	}
+
+	out := strings.Join(asm, ",")
+	if !strings.HasPrefix(out, "push 256,push 0,store,") ||
+		!strings.HasSuffix(out, "push 264,push 4,store,push 265,push -4,store,push 263,push 2") {
+		t.Fatalf("Unexpected output: %s", out)
+	}
+
+	src = ".equ A 1\n.equ A 2\n.equ 1x 3\n.equ B\n.equ C (A\n\tpush D\n\tpush 1 / (A - 1)"
+	expected := []string{
+		"line 2:6: constant \"A\" already defined at line 1:1",
+		"line 3:6: expected a name for .equ",
+		"line 4:7: missing value",
+		"line 5:10: missing )",
+		"line 6:7: undefined constant \"D\"",
+		"line 7:9: division by zero",
+	}
+
+	_, err = Assemble(strings.NewReader(src))
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, received %v", err)
+	}
+
+	if len(list) != len(expected) {
+		t.Fatalf("Received %d errors; expected %d: %v", len(list), len(expected), list)
+	}
+
+	for i, exp := range expected {
+		if list[i].Error() != exp {
+			t.Logf("Received %q; expected %q", list[i].Error(), exp)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package asm
+
+import (
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// formatIndent is the indentation of everything that isn't a label or a
+// directive.
+const formatIndent = "    "
+
+// numberOperands are the mnemonics whose operands are numbers or
+// expressions, besides the instructions that take a number.
+var numberOperands = map[string]bool{
+	".equ":   true,
+	".var":   true,
+	".array": true,
+	".data":  true,
+	".words": true,
+	".zero":  true,
+}
+
+// formatLine is a line of source split into its code and its comment.
+type formatLine struct {
+	code    string
+	comment string
+}
+
+// Format returns the source in the canonical layout.  Labels and directives
+// are flush left and everything else is indented.  Mnemonics are lower
+// case, spaces and tabs outside of quotes become a single space, and number
+// literals are written without leading zeros or a plus sign and with lower
+// case prefixes and upper case hex digits.  Comments on their own line are
+// indented like the code after them, and the comments after code in a block
+// of lines are lined up.  Runs of blank lines become a single one.
+func Format(src []byte, dialect *inst.Dialect) []byte {
+	a := &Assembler{Dialect: dialect}
+	lines := []*formatLine{}
+
+	for _, text := range sourceLines(string(src)) {
+		code := stripComment(text)
+		lines = append(lines, &formatLine{
+			code:    strings.TrimSpace(code),
+			comment: strings.TrimSpace(text[len(code):]),
+		})
+	}
+
+	// indent comments on their own like the next line of code
+	indent := ""
+	for i := len(lines) - 1; i >= 0; i-- {
+		l := lines[i]
+		switch {
+		case l.code != "":
+			l.code = a.formatStatement(l.code)
+			indent = l.code[:len(l.code)-len(strings.TrimLeft(l.code, " "))]
+		case l.comment != "":
+			l.comment = indent + l.comment
+		}
+	}
+
+	sb := &strings.Builder{}
+	blank := false
+	for i := 0; i < len(lines); {
+		if lines[i].code == "" && lines[i].comment == "" {
+			blank = true
+			i++
+			continue
+		}
+
+		if blank && sb.Len() > 0 {
+			sb.WriteString("\n")
+		}
+		blank = false
+
+		// the block runs to the next blank line
+		end := i
+		width := 0
+		for ; end < len(lines) && (lines[end].code != "" || lines[end].comment != ""); end++ {
+			l := lines[end]
+			if n := utf8.RuneCountInString(l.code); l.code != "" && l.comment != "" && n > width {
+				width = n
+			}
+		}
+
+		for _, l := range lines[i:end] {
+			sb.WriteString(l.code)
+			if l.code != "" && l.comment != "" {
+				sb.WriteString(strings.Repeat(" ", width-utf8.RuneCountInString(l.code)+1))
+			}
+			sb.WriteString(l.comment + "\n")
+		}
+		i = end
+	}
+
+	return []byte(sb.String())
+}
+
+// formatStatement returns a line of code, without its comment, in the
+// canonical layout.
+func (a *Assembler) formatStatement(code string) string {
+	stmt := parseStatement(line{text: code})
+
+	if strings.HasSuffix(stmt.mnemonic, ":") && stmt.operand == "" {
+		return code
+	}
+
+	text := stmt.mnemonic
+	if stmt.operand != "" {
+		name := a.native(stmt.mnemonic)
+		numbers := numberOperands[stmt.mnemonic] || name == "load" || name == "store"
+		if def, ok := instructionSet[name]; ok && def.operand == opNumber {
+			numbers = true
+		}
+
+		op := stmt.operand
+		if stmt.mnemonic == ".equ" || stmt.mnemonic == ".var" || stmt.mnemonic == ".array" {
+			// the name is followed by an expression of its own
+			if end := strings.IndexAny(op, " \t"); end != -1 {
+				text += " " + op[:end]
+				op = strings.TrimLeft(op[end:], " \t")
+			}
+		}
+
+		text += " " + formatOperand(op, numbers)
+	}
+
+	_, pseudo := pseudoInstructions[stmt.mnemonic]
+	if a.native(stmt.mnemonic) == "label" || strings.HasPrefix(stmt.mnemonic, ".") && !pseudo {
+		return text
+	}
+	return formatIndent + text
+}
+
+// formatOperand squeezes runs of spaces and tabs in an operand into single
+// spaces and, if numbers is true, rewrites its number literals.  Quoted
+// text is left alone, as is anything that isn't a valid number.
+func formatOperand(op string, numbers bool) string {
+	sb := &strings.Builder{}
+	var quote byte
+	escaped := false
+
+	for i := 0; i < len(op); i++ {
+		c := op[i]
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && c == '\\':
+			escaped = true
+		case quote != 0 && c == quote:
+			quote = 0
+		case quote != 0:
+		case c == '\'' || c == '"':
+			quote = c
+		case c == ' ' || c == '\t':
+			if op[i-1] != ' ' && op[i-1] != '\t' {
+				sb.WriteByte(' ')
+			}
+			continue
+		case numbers && c == '+' && i+1 < len(op) && op[i+1] >= '0' && op[i+1] <= '9' && isUnary(sb.String()):
+			continue
+		case numbers && c >= '0' && c <= '9' && (i == 0 || !isIdentByte(op[i-1])):
+			end := i
+			for end < len(op) && isIdentByte(op[end]) {
+				end++
+			}
+
+			sb.WriteString(formatNumber(op[i:end]))
+			i = end - 1
+			continue
+		}
+		sb.WriteByte(c)
+	}
+	return sb.String()
+}
+
+// isUnary returns true if an operator after the text so far has nothing
+// on its left.
+func isUnary(text string) bool {
+	text = strings.TrimRight(text, " ")
+	return text == "" || strings.IndexByte("+-*/%(<>,", text[len(text)-1]) != -1
+}
+
+// formatNumber returns a number literal without a sign in the canonical
+// form, or as it is if it isn't a valid number.
+func formatNumber(s string) string {
+	n, err := parseNumber(s)
+	if err != nil {
+		return s
+	}
+
+	prefix := ""
+	base := 10
+	if len(s) > 2 {
+		switch strings.ToLower(s[:2]) {
+		case "0x":
+			prefix, base = "0x", 16
+		case "0o":
+			prefix, base = "0o", 8
+		case "0b":
+			prefix, base = "0b", 2
+		}
+	}
+	return prefix + strings.ToUpper(strconv.FormatUint(uint64(n), base))
+}
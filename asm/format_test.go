@@ -0,0 +1,58 @@
+package asm
+
+import (
+	"testing"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+func TestFormat(t *testing.T) {
+	src := "# Count down\r\n" +
+		"\r\n" +
+		"\r\n" +
+		".EQU START  +0x0a # start here\r\n" +
+		"  PUSH   START\r\n" +
+		"Loop:\r\n" +
+		"\t# print it\r\n" +
+		"   duplicate\r\n" +
+		"printnumber       # the number\r\n" +
+		"\tpush 001 # one\r\n" +
+		"subtract\r\n" +
+		"  push  -+2*(+1) + +START +3\r\n" +
+		"  .print \"#  0x01 \"\r\n" +
+		"\tduplicate\r\n" +
+		"    jumpzero   007    # done\r\n" +
+		"jump loop\r\n" +
+		"label 007\r\n" +
+		"  .words 0B0011, 'a', 0O17\r\n" +
+		"stop\r\n" +
+		"\r\n"
+
+	expected := "# Count down\n" +
+		"\n" +
+		".equ START 0xA   # start here\n" +
+		"    push START\n" +
+		"Loop:\n" +
+		"    # print it\n" +
+		"    duplicate\n" +
+		"    printnumber  # the number\n" +
+		"    push 1       # one\n" +
+		"    subtract\n" +
+		"    push -2*(1) + +START +3\n" +
+		"    .print \"#  0x01 \"\n" +
+		"    duplicate\n" +
+		"    jumpzero 007 # done\n" +
+		"    jump loop\n" +
+		"label 007\n" +
+		".words 0b11, 'a', 0o17\n" +
+		"    stop\n"
+
+	out := string(Format([]byte(src), inst.Native))
+	if out != expected {
+		t.Fatalf("Unexpected format.\n Rec: %q\n Exp: %q", out, expected)
+	}
+
+	if again := string(Format([]byte(out), inst.Native)); again != out {
+		t.Fatalf("Formatting twice changed the source.\n Rec: %q\n Exp: %q", again, out)
+	}
+}
@@ -0,0 +1,151 @@
+package asm
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// listingRow is one line of the listing.  Source lines that didn't
+// assemble to anything have no instruction columns.
+type listingRow struct {
+	index, offset, bytes, asm string
+	source                    string
+}
+
+// WriteListing writes each source line next to the instructions assembled
+// from it, followed by the symbols and variables.  Lines from macro
+// expansions are marked with a +.  Each instruction has its
+// index, byte offset in the whitespace output, encoding with S for space, T
+// for tab, and L for newline, and assembly with label names filled in.
+func (p *Program) WriteListing(w io.Writer) error {
+	names := p.names()
+
+	rows := []listingRow{}
+	listed := make(map[string]int) // last line listed from each file
+	file := ""
+
+	// listSource adds rows for the lines of a file that haven't been
+	// listed yet, up to but not including line.
+	listSource := func(filename string, line int) {
+		if filename != file || len(rows) == 0 {
+			file = filename
+			rows = append(rows, listingRow{source: "# " + displayName(filename)})
+		}
+
+		src := p.sources[filename]
+		for l := listed[filename] + 1; l < line && l <= len(src); l++ {
+			rows = append(rows, listingRow{source: fmt.Sprintf("%4d  %s", l, src[l-1])})
+		}
+		if line-1 > listed[filename] {
+			listed[filename] = line - 1
+		}
+	}
+
+	offset := 0
+	var last Position
+	for idx, i := range p.Instructions {
+		row := listingRow{
+			index:  fmt.Sprint(idx),
+			offset: fmt.Sprint(offset),
+			bytes:  inst.Visible(i.Wsp()),
+			asm:    i.Asm(),
+		}
+		offset += len(i.Wsp())
+
+		if fc, ok := i.(inst.FlowControl); ok {
+			if name, ok := names[fc.Label()]; ok {
+				row.asm += " # " + name
+			}
+		}
+
+		var pos Position
+		if idx < len(p.Positions) {
+			pos = p.Positions[idx]
+		}
+
+		if idx == 0 || pos.Filename != last.Filename || pos.Line != last.Line || pos.Parent != last.Parent {
+			// list the macro invocations and includes this came from first
+			chain := []Position{}
+			for e := pos.Parent; e != nil; e = e.Pos.Parent {
+				chain = append([]Position{e.Pos}, chain...)
+			}
+			for _, c := range chain {
+				if c.Line > listed[c.Filename] {
+					listSource(c.Filename, c.Line+1)
+				}
+			}
+
+			listSource(pos.Filename, pos.Line)
+			mark := " "
+			if pos.Parent != nil && pos.Parent.Macro != "" {
+				mark = "+"
+			}
+			row.source = fmt.Sprintf("%4d%s %s", pos.Line, mark, p.sourceLine(pos))
+			if pos.Line > listed[pos.Filename] {
+				listed[pos.Filename] = pos.Line
+			}
+		}
+		last = pos
+
+		rows = append(rows, row)
+	}
+
+	// the rest of the main file
+	listSource(p.filename, len(p.sources[p.filename])+1)
+
+	widths := [4]int{len("INDEX"), len("OFFSET"), len("BYTES"), len("ASSEMBLY")}
+	for _, r := range rows {
+		for c, s := range []string{r.index, r.offset, r.bytes, r.asm} {
+			if len(s) > widths[c] {
+				widths[c] = len(s)
+			}
+		}
+	}
+
+	lines := []string{fmt.Sprintf("%*s  %*s  %-*s  %-*s  SOURCE",
+		widths[0], "INDEX", widths[1], "OFFSET", widths[2], "BYTES", widths[3], "ASSEMBLY")}
+	for _, r := range rows {
+		lines = append(lines, strings.TrimRight(fmt.Sprintf("%*s  %*s  %-*s  %-*s  %s",
+			widths[0], r.index, widths[1], r.offset, widths[2], r.bytes, widths[3], r.asm, r.source), " "))
+	}
+
+	if len(p.Symbols) > 0 {
+		lines = append(lines, "", "SYMBOLS")
+		for _, sym := range p.Symbols {
+			lines = append(lines, fmt.Sprintf("  %-20s %-10s %s", sym.Name, inst.Visible(sym.Encoding), sym.Pos))
+		}
+	}
+
+	if len(p.Variables) > 0 {
+		lines = append(lines, "", "VARIABLES")
+		for _, v := range p.Variables {
+			addr := fmt.Sprint(v.Addr)
+			if v.Size > 1 {
+				addr = fmt.Sprintf("%d-%d", v.Addr, v.Addr+v.Size-1)
+			}
+			lines = append(lines, fmt.Sprintf("  %-20s %-10s %s", v.Name, addr, v.Pos))
+		}
+	}
+
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	return err
+}
+
+// sourceLine returns the text of the line at pos, if it's known.
+func (p *Program) sourceLine(pos Position) string {
+	src := p.sources[pos.Filename]
+	if pos.Line < 1 || pos.Line > len(src) {
+		return ""
+	}
+	return src[pos.Line-1]
+}
+
+func displayName(filename string) string {
+	if filename == "" {
+		return "<input>"
+	}
+	return filename
+}
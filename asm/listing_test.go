@@ -0,0 +1,68 @@
+package asm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestListing(t *testing.T) {
+	src := `# count down
+.var n
+
+.macro dec
+	push 1
+	subtract
+.endm
+
+main:
+	push 2
+1:
+	dec
+	jumpzero 1f
+	jump 1b
+1:
+	stop
+`
+	prog, err := NewAssembler(strings.NewReader(src), "count.wsa").Assemble()
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	expected := `INDEX  OFFSET  BYTES   ASSEMBLY        SOURCE
+                                       # count.wsa
+                                          1  # count down
+                                          2  .var n
+                                          3
+                                          4  .macro dec
+                                          5  	push 1
+                                          6  	subtract
+                                          7  .endm
+                                          8
+    0       0  LSSSL   label s # main     9  main:
+    1       5  SSSTSL  push 2            10  	push 2
+    2      11  LSSTL   label t           11  1:
+                                         12  	dec
+    3      16  SSSTL   push 1             5+ 	push 1
+    4      21  TSST    subtract           6+ 	subtract
+    5      25  LTSSSL  jumpzero ss       13  	jumpzero 1f
+    6      31  LSLTL   jump t            14  	jump 1b
+    7      36  LSSSSL  label ss          15  1:
+    8      42  LLL     stop              16  	stop
+
+SYMBOLS
+  main                 S          count.wsa:9:1
+
+VARIABLES
+  n                    0          count.wsa:2:1
+`
+
+	out := &strings.Builder{}
+	err = prog.WriteListing(out)
+	if err != nil {
+		t.Fatalf("WriteListing() error: %s", err)
+	}
+
+	if out.String() != expected {
+		t.Fatalf("Unexpected listing\nRec:\n%s\nExp:\n%s", out.String(), expected)
+	}
+}
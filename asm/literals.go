@@ -0,0 +1,86 @@
+package asm
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// parseNumber parses a number operand.  Numbers can be decimal, or hex,
+// octal, and binary with a 0x, 0o, or 0b prefix.  A character in single
+// quotes is its code point.
+func parseNumber(s string) (int64, error) {
+	if strings.HasPrefix(s, "'") {
+		return parseChar(s)
+	}
+
+	digits := s
+	negative := false
+	if strings.HasPrefix(digits, "-") {
+		negative = true
+		digits = digits[1:]
+	} else if strings.HasPrefix(digits, "+") {
+		digits = digits[1:]
+	}
+
+	base := 10
+	if len(digits) > 2 && digits[0] == '0' {
+		switch digits[1] {
+		case 'x', 'X':
+			base = 16
+		case 'o', 'O':
+			base = 8
+		case 'b', 'B':
+			base = 2
+		}
+		if base != 10 {
+			digits = digits[2:]
+		}
+	}
+
+	// Parse without the sign so the most negative number works.
+	n, err := strconv.ParseUint(digits, base, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid number %q", s)
+	}
+
+	if negative {
+		if n > 1<<63 {
+			return 0, fmt.Errorf("number out of range %q", s)
+		}
+		return -int64(n), nil
+	}
+
+	if n > 1<<63-1 {
+		return 0, fmt.Errorf("number out of range %q", s)
+	}
+	return int64(n), nil
+}
+
+// parseChar parses a character literal like 'a' or '\n'.  Escapes are the
+// same as in Go.
+func parseChar(s string) (int64, error) {
+	if len(s) < 3 || s[0] != '\'' || s[len(s)-1] != '\'' {
+		return 0, fmt.Errorf("invalid character %s", s)
+	}
+
+	r, _, tail, err := strconv.UnquoteChar(s[1:len(s)-1], '\'')
+	if err != nil || tail != "" {
+		return 0, fmt.Errorf("invalid character %s", s)
+	}
+	return int64(r), nil
+}
+
+// parseString parses a string literal in double quotes.  Escapes are the
+// same as in Go.
+func parseString(s string) (string, error) {
+	if !strings.HasPrefix(s, "\"") {
+		return "", fmt.Errorf("expected a string in double quotes")
+	}
+
+	str, err := strconv.Unquote(s)
+	if err != nil {
+		return "", fmt.Errorf("invalid string %s", s)
+	}
+	return str, nil
+}
@@ -0,0 +1,75 @@
+package asm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	tests := []struct {
+		Input  string
+		Output int64
+	}{
+		{"0", 0},
+		{"75", 75},
+		{"-75", -75},
+		{"+3", 3},
+		{"0x4B", 75},
+		{"-0x4b", -75},
+		{"0o113", 75},
+		{"0b1001011", 75},
+		{"010", 10},
+		{"'K'", 75},
+		{"'\\n'", 10},
+		{"'\\''", 39},
+		{"'\\x4b'", 75},
+		{"'é'", 233},
+		{"'\\xff'", 255},
+		{"-9223372036854775808", -9223372036854775808},
+		{"0x7fffffffffffffff", 9223372036854775807},
+	}
+
+	for _, tst := range tests {
+		n, err := parseNumber(tst.Input)
+		if err != nil {
+			t.Logf("parseNumber(%q) error: %s", tst.Input, err)
+			t.Fail()
+			continue
+		}
+
+		if n != tst.Output {
+			t.Logf("parseNumber(%q) = %d; expected %d", tst.Input, n, tst.Output)
+			t.Fail()
+		}
+	}
+
+	for _, bad := range []string{"", "-", "0x", "12a", "0b102", "'ab'", "''", "'a", "9223372036854775808", "--1"} {
+		if _, err := parseNumber(bad); err == nil {
+			t.Logf("parseNumber(%q) didn't return an error", bad)
+			t.Fail()
+		}
+	}
+}
+
+func TestStringPseudoInstructions(t *testing.T) {
+	prog, err := Assemble(strings.NewReader(".print \"Hi\\n\" # comment\n.pushstr \"a#b\""))
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	asm := []string{}
+	for _, i := range prog.Instructions {
+		asm = append(asm, i.Asm())
+	}
+
+	expected := "push 72,printchar,push 105,printchar,push 10,printchar," +
+		"push 0,push 98,push 35,push 97"
+	if strings.Join(asm, ",") != expected {
+		t.Fatalf("Unexpected output\n Rec: %s\n Exp: %s", strings.Join(asm, ","), expected)
+	}
+
+	_, err = Assemble(strings.NewReader(".print hello\n.pushstr \"\\q\""))
+	if err == nil || err.Error() != "line 1:8: expected a string in double quotes for .print (and 1 more errors)" {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Macros nested deeper than this are assumed to be recursive.
+const maxMacroDepth = 64
+
+// macro is a named block of lines defined with .macro and .endm.
+type macro struct {
+	name   string
+	params []string
+	body   []line
+	pos    Position
+}
+
+// defineMacro reads the macro starting at lines[start] and returns the index
+// of its .endm.
+func (a *Assembler) defineMacro(lines []line, start int) int {
+	stmt := parseStatement(lines[start])
+	fields := strings.FieldsFunc(stmt.operand, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t'
+	})
+
+	end := start + 1
+	body := []line{}
+	for ; end < len(lines); end++ {
+		s := parseStatement(lines[end])
+		if s.mnemonic == ".endm" {
+			break
+		}
+		if s.mnemonic == ".macro" {
+			a.errorf(s.pos, "macro definitions cannot be nested")
+			continue
+		}
+		body = append(body, lines[end])
+	}
+
+	if end == len(lines) {
+		a.errorf(stmt.pos, "missing .endm for .macro")
+	}
+
+	if len(fields) == 0 {
+		a.errorf(stmt.pos, "missing name for .macro")
+		return end
+	}
+
+	m := &macro{
+		name:   strings.ToLower(fields[0]),
+		params: fields[1:],
+		body:   body,
+		pos:    stmt.pos,
+	}
+
+	if !isLabelName(m.name) || strings.HasPrefix(m.name, ".") {
+		a.errorf(stmt.opPos, "invalid macro name %q", fields[0])
+		return end
+	}
+
+	if _, ok := instructionSet[a.native(m.name)]; ok {
+		a.errorf(stmt.opPos, "macro %q has the same name as an instruction", m.name)
+		return end
+	}
+
+	if prev, ok := a.macros[m.name]; ok {
+		a.errorf(stmt.opPos, "macro %q already defined at %s", m.name, prev.pos)
+		return end
+	}
+
+	seen := make(map[string]bool)
+	for _, p := range m.params {
+		if !isLabelName(p) {
+			a.errorf(stmt.opPos, "invalid macro parameter %q", p)
+			return end
+		}
+		if seen[p] {
+			a.errorf(stmt.opPos, "duplicate macro parameter %q", p)
+			return end
+		}
+		seen[p] = true
+	}
+
+	a.macros[m.name] = m
+	return end
+}
+
+// expandMacro returns the body of the macro with its parameters replaced by
+// the arguments of the invocation.
+func (a *Assembler) expandMacro(m *macro, stmt statement, depth int) []line {
+	if depth >= maxMacroDepth {
+		a.errorf(stmt.pos, "macro %s nested too deeply; is it recursive?", m.name)
+		return nil
+	}
+
+	args := splitArgs(stmt.operand)
+	if len(args) != len(m.params) {
+		a.errorf(stmt.pos, "macro %s takes %d arguments, %d given", m.name, len(m.params), len(args))
+		return nil
+	}
+
+	a.expansions++
+	exp := &Expansion{Macro: m.name, Pos: stmt.pos}
+
+	lines := []line{}
+	for _, l := range m.body {
+		l.pos.Parent = exp
+		l.text = substitute(l.text, m.params, args, a.expansions)
+		lines = append(lines, l)
+	}
+
+	return a.preprocess(lines, depth+1)
+}
+
+// substitute replaces \param with its argument and \@ with a number that is
+// unique to each expansion.  A backslash followed by anything else is left
+// alone.
+func substitute(text string, params, args []string, id int) string {
+	sb := &strings.Builder{}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] != '\\' || i+1 == len(text) {
+			sb.WriteByte(text[i])
+			continue
+		}
+
+		if text[i+1] == '@' {
+			fmt.Fprint(sb, id)
+			i++
+			continue
+		}
+
+		end := i + 1
+		for end < len(text) && isIdentByte(text[end]) {
+			end++
+		}
+
+		found := false
+		for p, param := range params {
+			if param == text[i+1:end] {
+				sb.WriteString(args[p])
+				found = true
+				break
+			}
+		}
+
+		if found {
+			i = end - 1
+		} else {
+			sb.WriteByte(text[i])
+		}
+	}
+
+	return sb.String()
+}
+
+func isIdentByte(b byte) bool {
+	return b == '_' || b == '.' ||
+		(b >= 'a' && b <= 'z') ||
+		(b >= 'A' && b <= 'Z') ||
+		(b >= '0' && b <= '9')
+}
+
+// splitArgs splits on commas that aren't inside of quotes or parentheses.
+func splitArgs(operand string) []string {
+	if strings.TrimSpace(operand) == "" {
+		return nil
+	}
+
+	args := []string{}
+	var quote rune
+	escaped := false
+	parens := 0
+	start := 0
+
+	for i, r := range operand {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '(':
+			parens++
+		case r == ')':
+			parens--
+		case r == ',' && parens == 0:
+			args = append(args, strings.TrimSpace(operand[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(args, strings.TrimSpace(operand[start:]))
+}
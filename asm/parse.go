@@ -0,0 +1,318 @@
+package asm
+
+import (
+	"strings"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// line is a single non-empty line of source with its comment removed.
+type line struct {
+	pos  Position // first non-blank character
+	text string
+}
+
+// splitLines splits the source into lines, dropping comments and empty
+// lines.
+func splitLines(filename, src string) []line {
+	lines := []line{}
+
+	for i, l := range strings.Split(src, "\n") {
+		l = stripComment(strings.TrimRight(l, "\r"))
+
+		start := len(l) - len(strings.TrimLeft(l, " \t"))
+		l = strings.TrimSpace(l)
+		if l == "" {
+			continue
+		}
+
+		lines = append(lines, line{
+			pos:  Position{Filename: filename, Line: i + 1, Column: start + 1},
+			text: l,
+		})
+	}
+
+	return lines
+}
+
+// sourceLines splits the source into lines as they were written.
+func sourceLines(src string) []string {
+	lines := strings.Split(strings.TrimSuffix(src, "\n"), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], "\r")
+	}
+	return lines
+}
+
+// statement is a line split into its mnemonic and operand.
+type statement struct {
+	pos      Position // start of the mnemonic
+	mnemonic string   // lower case
+	operand  string
+	opPos    Position // start of the operand
+}
+
+// at returns the position of an offset into the operand.
+func (s statement) at(offset int) Position {
+	pos := s.opPos
+	pos.Column += offset
+	return pos
+}
+
+func parseStatement(l line) statement {
+	stmt := statement{pos: l.pos}
+
+	end := strings.IndexAny(l.text, " \t")
+	if end == -1 {
+		// point just past the mnemonic for missing operands
+		stmt.mnemonic = strings.ToLower(l.text)
+		stmt.opPos = l.pos
+		stmt.opPos.Column += len(l.text)
+		return stmt
+	}
+
+	stmt.mnemonic = strings.ToLower(l.text[:end])
+	rest := l.text[end:]
+	stmt.operand = strings.TrimSpace(rest)
+	stmt.opPos = l.pos
+	stmt.opPos.Column += end + len(rest) - len(strings.TrimLeft(rest, " \t"))
+	return stmt
+}
+
+// stripComment removes everything from the first # that isn't inside of
+// quotes.
+func stripComment(l string) string {
+	var quote rune
+	escaped := false
+	for i, r := range l {
+		switch {
+		case escaped:
+			escaped = false
+		case quote != 0 && r == '\\':
+			escaped = true
+		case quote != 0 && r == quote:
+			quote = 0
+		case quote != 0:
+		case r == '\'' || r == '"':
+			quote = r
+		case r == '#':
+			return l[:i]
+		}
+	}
+	return l
+}
+
+type operandType int
+
+const (
+	opNone operandType = iota
+	opNumber
+	opLabel
+)
+
+// instructionDef describes how to build an instruction from its mnemonic.
+type instructionDef struct {
+	operand operandType
+	build   func(n int64, label string) inst.Instruction
+}
+
+var instructionSet = map[string]instructionDef{
+	"push":  {opNumber, func(n int64, _ string) inst.Instruction { return &inst.Push{Value: n} }},
+	"copy":  {opNumber, func(n int64, _ string) inst.Instruction { return &inst.Copy{Value: n} }},
+	"slide": {opNumber, func(n int64, _ string) inst.Instruction { return &inst.Slide{Value: n} }},
+
+	"duplicate": {opNone, func(int64, string) inst.Instruction { return &inst.Duplicate{} }},
+	"swap":      {opNone, func(int64, string) inst.Instruction { return &inst.Swap{} }},
+	"discard":   {opNone, func(int64, string) inst.Instruction { return &inst.Discard{} }},
+
+	"add":      {opNone, func(int64, string) inst.Instruction { return &inst.Add{} }},
+	"subtract": {opNone, func(int64, string) inst.Instruction { return &inst.Subtract{} }},
+	"multiply": {opNone, func(int64, string) inst.Instruction { return &inst.Multiply{} }},
+	"divide":   {opNone, func(int64, string) inst.Instruction { return &inst.Divide{} }},
+	"modulo":   {opNone, func(int64, string) inst.Instruction { return &inst.Modulo{} }},
+
+	"store": {opNone, func(int64, string) inst.Instruction { return &inst.Store{} }},
+	"load":  {opNone, func(int64, string) inst.Instruction { return &inst.Load{} }},
+
+	"label":     {opLabel, func(_ int64, l string) inst.Instruction { return &inst.Label{Value: l} }},
+	"call":      {opLabel, func(_ int64, l string) inst.Instruction { return &inst.Call{Value: l} }},
+	"jump":      {opLabel, func(_ int64, l string) inst.Instruction { return &inst.Jump{Value: l} }},
+	"jumpzero":  {opLabel, func(_ int64, l string) inst.Instruction { return &inst.JumpZero{Value: l} }},
+	"jumpminus": {opLabel, func(_ int64, l string) inst.Instruction { return &inst.JumpMinus{Value: l} }},
+	"return":    {opNone, func(int64, string) inst.Instruction { return &inst.Return{} }},
+	"stop":      {opNone, func(int64, string) inst.Instruction { return &inst.Stop{} }},
+
+	"printchar":   {opNone, func(int64, string) inst.Instruction { return &inst.PrintChar{} }},
+	"printnumber": {opNone, func(int64, string) inst.Instruction { return &inst.PrintNumber{} }},
+	"readchar":    {opNone, func(int64, string) inst.Instruction { return &inst.ReadChar{} }},
+	"readnumber":  {opNone, func(int64, string) inst.Instruction { return &inst.ReadNumber{} }},
+}
+
+// native returns the mnemonic in the native dialect for a mnemonic in the
+// assembler's dialect.  Anything else is returned as-is.
+func (a *Assembler) native(mnemonic string) string {
+	if a.Dialect == nil {
+		return mnemonic
+	}
+
+	if c, ok := a.Dialect.Command(mnemonic); ok {
+		return inst.Native.Mnemonic(c)
+	}
+	return mnemonic
+}
+
+// pseudoInstructions expand into a sequence of instructions.
+var pseudoInstructions = map[string]func(a *Assembler, stmt statement) []inst.Instruction{
+	".print":   (*Assembler).pseudoPrint,
+	".pushstr": (*Assembler).pseudoPushString,
+}
+
+// pseudoPrint prints a string, one character at a time.
+func (a *Assembler) pseudoPrint(stmt statement) []inst.Instruction {
+	str, err := parseString(stmt.operand)
+	if err != nil {
+		a.errorf(stmt.opPos, "%s for .print", err)
+		return nil
+	}
+
+	lst := []inst.Instruction{}
+	for _, r := range str {
+		lst = append(lst, &inst.Push{Value: int64(r)}, &inst.PrintChar{})
+	}
+	return lst
+}
+
+// pseudoPushString pushes a zero followed by the string in reverse, leaving
+// the first character on top of the stack.
+func (a *Assembler) pseudoPushString(stmt statement) []inst.Instruction {
+	str, err := parseString(stmt.operand)
+	if err != nil {
+		a.errorf(stmt.opPos, "%s for .pushstr", err)
+		return nil
+	}
+
+	runes := []rune(str)
+	lst := []inst.Instruction{&inst.Push{Value: 0}}
+	for i := len(runes) - 1; i >= 0; i-- {
+		lst = append(lst, &inst.Push{Value: int64(runes[i])})
+	}
+	return lst
+}
+
+// labelRef is a label operand waiting for an encoding.
+type labelRef struct {
+	idx     int    // instruction index
+	name    string // full name, see scopeLabel
+	text    string // as written
+	pos     Position
+	def     instructionDef
+	defines bool // true for label definitions
+}
+
+// build turns lines into instructions and resolves their labels.
+func (a *Assembler) build(lines []line) *Program {
+	prog := &Program{}
+	refs := []labelRef{}
+	scope := newLabelScope()
+	a.fixed = a.scanData(lines)
+
+	for _, l := range lines {
+		stmt := parseStatement(l)
+
+		// name: is short for label name
+		if strings.HasSuffix(stmt.mnemonic, ":") && stmt.operand == "" {
+			stmt.operand = strings.TrimSuffix(l.text, ":")
+			stmt.mnemonic = "label"
+			stmt.opPos = stmt.pos
+		}
+
+		if stmt.mnemonic == ".equ" {
+			a.defineConstant(stmt)
+			continue
+		}
+
+		if stmt.mnemonic == ".var" || stmt.mnemonic == ".array" {
+			if v := a.declareVariable(stmt); v != nil {
+				prog.Variables = append(prog.Variables, *v)
+			}
+			continue
+		}
+
+		if parse, ok := dataDirectives[stmt.mnemonic]; ok {
+			if r := parse(a, stmt); r != nil {
+				a.addRegion(r)
+			}
+			continue
+		}
+
+		name := a.native(stmt.mnemonic)
+
+		expand, ok := pseudoInstructions[stmt.mnemonic]
+		if (name == "load" || name == "store") && stmt.operand != "" {
+			expand, ok = (*Assembler).heapAccess, true
+		}
+
+		if ok {
+			for _, i := range expand(a, stmt) {
+				prog.Instructions = append(prog.Instructions, i)
+				prog.Positions = append(prog.Positions, stmt.pos)
+			}
+			continue
+		}
+
+		def, ok := instructionSet[name]
+		if !ok {
+			a.errorf(stmt.pos, "unknown instruction %q%s", stmt.mnemonic, didYouMean(stmt.mnemonic, a.mnemonics()))
+			continue
+		}
+
+		if def.operand == opNone && stmt.operand != "" {
+			a.errorf(stmt.opPos, "unexpected operand for %s", stmt.mnemonic)
+			continue
+		}
+		if def.operand != opNone && stmt.operand == "" {
+			a.errorf(stmt.pos, "missing operand for %s", stmt.mnemonic)
+			continue
+		}
+
+		var n int64
+		switch def.operand {
+		case opNumber:
+			n, ok = a.evaluate(stmt.operand, stmt.opPos)
+			if !ok {
+				continue
+			}
+		case opLabel:
+			ref := labelRef{
+				idx:     len(prog.Instructions),
+				text:    stmt.operand,
+				pos:     stmt.opPos,
+				def:     def,
+				defines: name == "label",
+			}
+
+			ref.name, ok = a.scopeLabel(scope, refs, ref, len(refs))
+			if !ok {
+				continue
+			}
+			refs = append(refs, ref)
+		}
+
+		prog.Instructions = append(prog.Instructions, def.build(n, ""))
+		prog.Positions = append(prog.Positions, stmt.pos)
+	}
+
+	// The data goes before everything else so it's in place by the time
+	// the first instruction runs.
+	if len(a.regions) > 0 {
+		data, pos := a.dataPrologue()
+		prog.Instructions = append(data, prog.Instructions...)
+		prog.Positions = append(pos, prog.Positions...)
+		for i := range refs {
+			refs[i].idx += len(data)
+		}
+	}
+
+	a.resolveLabels(prog, refs)
+	return prog
+}
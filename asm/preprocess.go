@@ -0,0 +1,148 @@
+package asm
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// preprocess handles directives, expanding macro invocations and includes.
+// depth is the number of macro expansions the lines are nested in.
+func (a *Assembler) preprocess(lines []line, depth int) []line {
+	out := []line{}
+	conds := []*conditional{}
+
+	for i := 0; i < len(lines); i++ {
+		stmt := parseStatement(lines[i])
+
+		if a.conditional(&conds, stmt) || !activeConditional(conds) {
+			continue
+		}
+
+		switch stmt.mnemonic {
+		case ".macro":
+			i = a.defineMacro(lines, i)
+			continue
+		case ".endm":
+			a.errorf(stmt.pos, ".endm without .macro")
+			continue
+		case ".include":
+			out = append(out, a.include(stmt, depth)...)
+			continue
+		case ".once":
+			if stmt.operand != "" {
+				a.errorf(stmt.opPos, "unexpected operand for .once")
+			}
+			a.once[a.fileKey(stmt.pos.Filename)] = true
+			continue
+		case ".define", ".undef":
+			a.define(stmt)
+			continue
+		}
+
+		l := a.applyDefines(lines[i], stmt)
+		stmt = parseStatement(l)
+
+		if m, ok := a.macros[stmt.mnemonic]; ok {
+			out = append(out, a.expandMacro(m, stmt, depth)...)
+			continue
+		}
+
+		out = append(out, l)
+	}
+
+	for _, c := range conds {
+		a.errorf(c.pos, "missing .endif for %s", c.directive)
+	}
+
+	return out
+}
+
+// fileKey returns the name used to tell if two paths are the same file.
+func (a *Assembler) fileKey(filename string) string {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return filepath.Clean(filename)
+	}
+	return abs
+}
+
+// findInclude looks for the file next to the one including it, then in
+// each of the include paths.
+func (a *Assembler) findInclude(name string, from Position) (string, error) {
+	if filepath.IsAbs(name) {
+		_, err := os.Stat(name)
+		return name, err
+	}
+
+	dirs := []string{filepath.Dir(from.Filename)}
+	dirs = append(dirs, a.IncludePaths...)
+
+	var firstErr error
+	for _, dir := range dirs {
+		path := filepath.Join(dir, name)
+		_, err := os.Stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return "", firstErr
+}
+
+// include returns the preprocessed lines of the file named by an .include
+// statement.
+func (a *Assembler) include(stmt statement, depth int) []line {
+	name, err := strconv.Unquote(stmt.operand)
+	if err != nil || !strings.HasPrefix(stmt.operand, "\"") {
+		a.errorf(stmt.opPos, "expected a quoted filename for .include")
+		return nil
+	}
+
+	path, err := a.findInclude(name, stmt.pos)
+	if err != nil {
+		a.errorf(stmt.opPos, "unable to find included file %q", name)
+		return nil
+	}
+
+	key := a.fileKey(path)
+	if a.once[key] {
+		return nil
+	}
+
+	for idx, active := range a.including {
+		if active != key {
+			continue
+		}
+
+		chain := []string{}
+		for _, f := range a.including[idx:] {
+			chain = append(chain, filepath.Base(f))
+		}
+		a.errorf(stmt.pos, "include cycle: %s -> %s", strings.Join(chain, " -> "), filepath.Base(path))
+		return nil
+	}
+
+	src, err := os.ReadFile(path)
+	if err != nil {
+		a.errorf(stmt.opPos, "unable to read included file: %s", err)
+		return nil
+	}
+
+	a.sources[path] = sourceLines(string(src))
+	exp := &Expansion{Pos: stmt.pos}
+	lines := splitLines(path, string(src))
+	for i := range lines {
+		lines[i].pos.Parent = exp
+	}
+
+	a.including = append(a.including, key)
+	lines = a.preprocess(lines, depth)
+	a.including = a.including[:len(a.including)-1]
+
+	return lines
+}
@@ -0,0 +1,102 @@
+package asm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// directives that aren't in pseudoInstructions or dataDirectives, for
+// suggestions.
+var directives = []string{
+	".macro", ".endm", ".include", ".once", ".define", ".undef",
+	".ifdef", ".ifndef", ".else", ".endif", ".equ", ".var", ".array",
+}
+
+// editDistance returns the number of single character insertions,
+// deletions, substitutions, and swaps of neighbors to turn a into b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	d := make([][]int, len(ra)+1)
+	for i := range d {
+		d[i] = make([]int, len(rb)+1)
+		d[i][0] = i
+	}
+	for j := range d[0] {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(ra); i++ {
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+
+			d[i][j] = minInt(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && ra[i-1] == rb[j-2] && ra[i-2] == rb[j-1] {
+				d[i][j] = minInt(d[i][j], d[i-2][j-2]+1)
+			}
+		}
+	}
+	return d[len(ra)][len(rb)]
+}
+
+func minInt(n ...int) int {
+	m := n[0]
+	for _, v := range n[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
+
+// suggest returns the candidate closest to name, if it's close enough to
+// probably be a typo.  Otherwise it returns an empty string.
+func suggest(name string, candidates []string) string {
+	sorted := append([]string{}, candidates...)
+	sort.Strings(sorted)
+
+	best, bestDist := "", len([]rune(name))/3+1
+	for _, c := range sorted {
+		if c == name {
+			continue
+		}
+		if d := editDistance(name, c); d < bestDist {
+			best, bestDist = c, d
+		}
+	}
+	return best
+}
+
+// didYouMean returns a suggestion to add to the end of an error message.
+func didYouMean(name string, candidates []string) string {
+	if s := suggest(name, candidates); s != "" {
+		return fmt.Sprintf("; did you mean %q?", s)
+	}
+	return ""
+}
+
+// mnemonics returns everything that can start a statement.
+func (a *Assembler) mnemonics() []string {
+	names := append([]string{}, directives...)
+	for n := range instructionSet {
+		names = append(names, n)
+	}
+	for n := range pseudoInstructions {
+		names = append(names, n)
+	}
+	for n := range dataDirectives {
+		names = append(names, n)
+	}
+	for n := range a.macros {
+		names = append(names, n)
+	}
+	if a.Dialect != nil {
+		for _, m := range a.Dialect.Mnemonics {
+			names = append(names, strings.ToLower(m))
+		}
+	}
+	return names
+}
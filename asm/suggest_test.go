@@ -0,0 +1,104 @@
+package asm
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		A, B     string
+		Distance int
+	}{
+		{"", "", 0},
+		{"push", "push", 0},
+		{"pusj", "push", 1},
+		{"psuh", "push", 1},
+		{"jumpzer", "jumpzero", 1},
+		{"printnum", "printnumber", 3},
+		{"", "add", 3},
+	}
+
+	for _, tst := range tests {
+		if d := editDistance(tst.A, tst.B); d != tst.Distance {
+			t.Logf("editDistance(%q, %q) = %d; expected %d", tst.A, tst.B, d, tst.Distance)
+			t.Fail()
+		}
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	src := `.equ SIZE 3
+.macro twice x
+	push \x
+	push \x
+.endm
+main:
+	psuh SIZE
+	twcie 1
+	push SIEZ
+	.prnit "hi"
+	jump mian
+.loop:
+	jump .lop
+	jump lopo
+	bogus
+`
+	expected := []string{
+		"line 7:2: unknown instruction \"psuh\"; did you mean \"push\"?",
+		"line 8:2: unknown instruction \"twcie\"; did you mean \"twice\"?",
+		"line 9:7: undefined constant \"SIEZ\"; did you mean \"SIZE\"?",
+		"line 10:2: unknown instruction \".prnit\"; did you mean \".print\"?",
+		"line 15:2: unknown instruction \"bogus\"",
+		"line 11:7: undefined label \"mian\"; did you mean \"main\"?",
+		"line 13:7: undefined label \".lop\"; did you mean \".loop\"?",
+		"line 14:7: undefined label \"lopo\"",
+	}
+
+	_, err := Assemble(strings.NewReader(src))
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, received %v", err)
+	}
+
+	if len(list) != len(expected) {
+		t.Fatalf("Received %d errors; expected %d: %v", len(list), len(expected), list)
+	}
+
+	for i, exp := range expected {
+		if list[i].Error() != exp {
+			t.Logf("Received %q; expected %q", list[i].Error(), exp)
+			t.Fail()
+		}
+	}
+}
+
+func TestErrorDetail(t *testing.T) {
+	src := ".macro m\n\tpush 1 2\n.endm\n\tm\nstop x"
+	expected := []string{
+		"line 2:9: unexpected \"2\"\n    \tpush 1 2\n    \t       ^\n\tin macro m at line 4:2",
+		"line 5:6: unexpected operand for stop\n    stop x\n         ^",
+	}
+
+	_, err := Assemble(strings.NewReader(src))
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, received %v", err)
+	}
+
+	if len(list) != len(expected) {
+		t.Fatalf("Received %d errors; expected %d: %v", len(list), len(expected), list)
+	}
+
+	for i, exp := range expected {
+		if list[i].Detail() != exp {
+			t.Logf("Received %q; expected %q", list[i].Detail(), exp)
+			t.Fail()
+		}
+	}
+
+	e := &Error{Pos: Position{Line: 3}, Msg: "whole line", Source: "push"}
+	if e.Detail() != "line 3: whole line\n    push" {
+		t.Fatalf("Unexpected detail: %q", e.Detail())
+	}
+}
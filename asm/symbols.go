@@ -0,0 +1,201 @@
+package asm
+
+import (
+	"fmt"
+	"strings"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// Labels made up of only the letters S and T are literal encodings, with S
+// for space and T for tab.  This is what Instruction.Asm() writes.
+func isLiteralLabel(name string) bool {
+	return strings.Trim(strings.ToLower(name), "st") == ""
+}
+
+func isLabelName(name string) bool {
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_', r == '.':
+		case r >= '0' && r <= '9' && i > 0:
+		default:
+			return false
+		}
+	}
+	return name != ""
+}
+
+// Numeric labels can be defined any number of times.  References to them
+// end in f or b for the next or previous definition.
+func isNumericLabel(name string) bool {
+	return name != "" && strings.Trim(name, "0123456789") == ""
+}
+
+// labelScope tracks what local and numeric labels refer to while building.
+type labelScope struct {
+	global  string           // last global label defined
+	numeric map[string]int   // number of definitions of each numeric label
+	forward map[string][]int // indexes of refs waiting for the next definition
+}
+
+func newLabelScope() *labelScope {
+	return &labelScope{
+		numeric: make(map[string]int),
+		forward: make(map[string][]int),
+	}
+}
+
+// scopeLabel returns the full name of a label operand.  Local labels start
+// with a dot and belong to the last global label.  Each definition of a
+// numeric label gets its own name.  A forward reference is filled in when
+// the next definition is found, so idx is the index ref will have in refs.
+// Definitions can only have a dot at the start, since the full name of a
+// local label is its global label and its name joined by the dot.
+func (a *Assembler) scopeLabel(s *labelScope, refs []labelRef, ref labelRef, idx int) (string, bool) {
+	name := ref.text
+
+	if ref.defines && strings.Contains(name[1:], ".") {
+		a.errorf(ref.pos, "label %q can only have a dot at the start", name)
+		return "", false
+	}
+
+	switch {
+	case strings.HasPrefix(name, ".") && len(name) > 1:
+		if s.global == "" {
+			a.errorf(ref.pos, "local label %q is not after a global label", name)
+			return "", false
+		}
+		return s.global + name, true
+
+	case ref.defines && isNumericLabel(name):
+		s.numeric[name]++
+		full := fmt.Sprintf("%s@%d", name, s.numeric[name])
+		for _, i := range s.forward[name] {
+			refs[i].name = full
+		}
+		delete(s.forward, name)
+		return full, true
+
+	case isNumericLabel(name[:len(name)-1]):
+		if ref.defines && strings.IndexByte("fb", name[len(name)-1]) != -1 {
+			a.errorf(ref.pos, "numeric label %q is a reference; define it as %q", name, name[:len(name)-1])
+			return "", false
+		}
+
+		num := name[:len(name)-1]
+		switch name[len(name)-1] {
+		case 'f':
+			s.forward[num] = append(s.forward[num], idx)
+		case 'b':
+			if n := s.numeric[num]; n > 0 {
+				return fmt.Sprintf("%s@%d", num, n), true
+			}
+		}
+		return name, true // undefined unless filled in later
+
+	case ref.defines && !isLiteralLabel(name):
+		s.global = name
+	}
+
+	return name, true
+}
+
+// suggestLabel returns a suggestion for an undefined label reference.
+// Labels that are local to the reference's scope are suggested by their
+// local name.
+func suggestLabel(ref labelRef, defined map[string]Position) string {
+	if isNumericLabel(strings.TrimRight(ref.text, "fb")) {
+		return ""
+	}
+
+	scope := strings.TrimSuffix(ref.name, ref.text)
+	names := []string{}
+	for name := range defined {
+		if strings.Contains(name, "@") {
+			continue
+		}
+		if scope != "" && strings.HasPrefix(name, scope+".") {
+			name = strings.TrimPrefix(name, scope)
+		}
+		names = append(names, name)
+	}
+
+	return didYouMean(ref.text, names)
+}
+
+// labelKey returns the name used to look up a label.  Literal labels are
+// not case sensitive.
+func labelKey(name string) string {
+	if isLiteralLabel(name) {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// resolveLabels assigns encodings to named labels and fills in the label
+// operands of every instruction in refs.  Local and numeric labels have
+// already been given their full names by scopeLabel.  Named labels are given the
+// shortest encodings that aren't used by a literal label, in the order
+// they are defined.
+func (a *Assembler) resolveLabels(prog *Program, refs []labelRef) {
+	encodings := make(map[string]string) // label key to encoding
+	defined := make(map[string]Position)
+	used := make(map[string]bool)
+
+	named := []labelRef{}
+	for _, ref := range refs {
+		if !ref.defines {
+			continue
+		}
+
+		key := labelKey(ref.name)
+		if !isLiteralLabel(ref.text) && !isLabelName(ref.text) && !isNumericLabel(ref.text) {
+			a.errorf(ref.pos, "invalid label name %q", ref.text)
+			continue
+		}
+
+		if pos, exist := defined[key]; exist {
+			a.errorf(ref.pos, "duplicate label %q, first defined at %s", ref.text, pos)
+			continue
+		}
+		defined[key] = ref.pos
+
+		if isLiteralLabel(ref.name) {
+			enc := strings.TrimSuffix(inst.EncodeLabel(key), "\n")
+			encodings[key] = enc
+			used[enc] = true
+		} else {
+			named = append(named, ref)
+		}
+	}
+
+	n := 0
+	for _, ref := range named {
+		for used[inst.NthLabel(n)] {
+			n++
+		}
+		enc := inst.NthLabel(n)
+		n++
+
+		encodings[ref.name] = enc
+
+		// numeric labels only have internal names, which can't be
+		// assembled
+		if isNumericLabel(ref.text) {
+			prog.NumericLabels = append(prog.NumericLabels, Symbol{Name: ref.text, Encoding: enc, Pos: ref.pos})
+		} else {
+			prog.Symbols = append(prog.Symbols, Symbol{Name: ref.name, Encoding: enc, Pos: ref.pos})
+		}
+	}
+
+	for _, ref := range refs {
+		enc, ok := encodings[labelKey(ref.name)]
+		if !ok {
+			if !ref.defines {
+				a.errorf(ref.pos, "undefined label %q%s", ref.text, suggestLabel(ref, defined))
+			}
+			continue
+		}
+		prog.Instructions[ref.idx] = ref.def.build(0, enc)
+	}
+}
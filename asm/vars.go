@@ -0,0 +1,155 @@
+package asm
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// Variable is heap space reserved with .var or .array.
+type Variable struct {
+	Name string
+	Addr int64
+	Size int64
+	Pos  Position
+}
+
+func (v Variable) String() string {
+	if v.Size == 1 {
+		return fmt.Sprintf("%s %d", v.Name, v.Addr)
+	}
+	return fmt.Sprintf("%s %d-%d", v.Name, v.Addr, v.Addr+v.Size-1)
+}
+
+// declareVariable handles .var NAME and .array NAME SIZE.  The name becomes
+// a constant holding the first address.
+func (a *Assembler) declareVariable(stmt statement) *Variable {
+	name := stmt.operand
+	rest := ""
+	offset := len(name)
+	if end := strings.IndexAny(name, " \t"); end != -1 {
+		rest = strings.TrimLeft(name[end:], " \t")
+		offset = len(name) - len(rest)
+		name = name[:end]
+	}
+
+	if !isLabelName(name) {
+		a.errorf(stmt.opPos, "expected a name for %s", stmt.mnemonic)
+		return nil
+	}
+
+	size := int64(1)
+	if stmt.mnemonic == ".var" {
+		if rest != "" {
+			a.errorf(stmt.at(offset), "unexpected size for .var; use .array")
+			return nil
+		}
+	} else {
+		var ok bool
+		size, ok = a.evaluate(rest, stmt.at(offset))
+		if !ok {
+			return nil
+		}
+		if size < 1 {
+			a.errorf(stmt.at(offset), "expected a positive size for .array")
+			return nil
+		}
+	}
+
+	if pos, exist := a.constantPos[name]; exist {
+		a.errorf(stmt.opPos, "constant %q already defined at %s", name, pos)
+		return nil
+	}
+
+	addr, ok := a.allocate(size)
+	if !ok {
+		a.errorf(stmt.pos, "no room in the heap for %s", name)
+		return nil
+	}
+
+	a.constants[name] = addr
+	a.constantPos[name] = stmt.pos
+	a.addRegion(&region{
+		pos:       stmt.pos,
+		directive: stmt.mnemonic + " " + name,
+		addr:      addr,
+		size:      size,
+	})
+
+	return &Variable{Name: name, Addr: addr, Size: size, Pos: stmt.pos}
+}
+
+// allocate returns the lowest address that isn't negative and has room for
+// size values without overlapping any data directive or any variable
+// declared so far.
+func (a *Assembler) allocate(size int64) (int64, bool) {
+	regions := append(append([]*region{}, a.fixed...), a.regions...)
+
+	addr := int64(0)
+	for moved := true; moved; {
+		moved = false
+		for _, r := range regions {
+			if addr > math.MaxInt64-size+1 {
+				return 0, false
+			}
+
+			if addr <= r.end() && r.addr <= addr+size-1 {
+				addr = r.end() + 1
+				moved = true
+			}
+		}
+	}
+	return addr, true
+}
+
+// scanData returns the regions of every data directive so variables can be
+// placed around them, even the ones declared after the variable.  The
+// directives and constants are evaluated on their own first, and anything
+// that can't be evaluated is left for build to report.
+func (a *Assembler) scanData(lines []line) []*region {
+	scan := &Assembler{
+		Dialect:     a.Dialect,
+		constants:   make(map[string]int64),
+		constantPos: make(map[string]Position),
+	}
+
+	for _, l := range lines {
+		stmt := parseStatement(l)
+		switch stmt.mnemonic {
+		case ".equ":
+			scan.defineConstant(stmt)
+		case ".var", ".array":
+			scan.declareVariable(stmt)
+		default:
+			if parse, ok := dataDirectives[stmt.mnemonic]; ok {
+				if r := parse(scan, stmt); r != nil {
+					scan.addRegion(r)
+				}
+			}
+		}
+	}
+
+	fixed := []*region{}
+	for _, r := range scan.regions {
+		if r.values != nil {
+			fixed = append(fixed, r)
+		}
+	}
+	return fixed
+}
+
+// heapAccess expands load ADDR and store ADDR.  Store takes the value from
+// the top of the stack.
+func (a *Assembler) heapAccess(stmt statement) []inst.Instruction {
+	addr, ok := a.evaluate(stmt.operand, stmt.opPos)
+	if !ok {
+		return nil
+	}
+
+	if a.native(stmt.mnemonic) == "load" {
+		return []inst.Instruction{&inst.Push{Value: addr}, &inst.Load{}}
+	}
+	return []inst.Instruction{&inst.Push{Value: addr}, &inst.Swap{}, &inst.Store{}}
+}
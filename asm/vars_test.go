@@ -0,0 +1,141 @@
+package asm
+
+import (
+	"strings"
+	"testing"
+
+	ws "github.com/zorchenhimer/whitespace"
+)
+
+func TestVariables(t *testing.T) {
+	src := `
+.zero 0 2
+.var count
+.array buf 3
+.data 6 "a"
+.var total
+
+	push 5
+	store count
+	push 'x'
+	store buf + 2
+	load count
+	load buf + 2
+	add
+	store total
+	load total
+	printnumber
+	stop
+`
+	prog, err := Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	expected := []string{"count 2", "buf 3-5", "total 8"}
+	if len(prog.Variables) != len(expected) {
+		t.Fatalf("Received %d variables; expected %d: %v", len(prog.Variables), len(expected), prog.Variables)
+	}
+
+	for i, exp := range expected {
+		if prog.Variables[i].String() != exp {
+			t.Logf("Received %q; expected %q", prog.Variables[i], exp)
+			t.Fail()
+		}
+	}
+
+	e, err := ws.NewEngine(strings.NewReader(prog.Wsp()))
+	if err != nil {
+		t.Fatalf("Engine creation fail: %s", err)
+	}
+
+	out := &strings.Builder{}
+	err = e.Run(nil, out)
+	if err != nil {
+		t.Fatalf("Run fail: %s", err)
+	}
+
+	if out.String() != "125" {
+		t.Fatalf("Unexpected output.\n Rec: %q\n Exp: %q", out.String(), "125")
+	}
+}
+
+func TestVariablesBeforeData(t *testing.T) {
+	src := `
+.var count
+.array buf 2
+.data 0 "hi"
+.zero 4 1
+.var total
+
+	push 7
+	store count
+	load 0
+	printchar
+	load count
+	printnumber
+	stop
+`
+	prog, err := Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	expected := []string{"count 3", "buf 5-6", "total 7"}
+	if len(prog.Variables) != len(expected) {
+		t.Fatalf("Received %d variables; expected %d: %v", len(prog.Variables), len(expected), prog.Variables)
+	}
+
+	for i, exp := range expected {
+		if prog.Variables[i].String() != exp {
+			t.Logf("Received %q; expected %q", prog.Variables[i], exp)
+			t.Fail()
+		}
+	}
+
+	e, err := ws.NewEngine(strings.NewReader(prog.Wsp()))
+	if err != nil {
+		t.Fatalf("Engine creation fail: %s", err)
+	}
+
+	out := &strings.Builder{}
+	err = e.Run(nil, out)
+	if err != nil {
+		t.Fatalf("Run fail: %s", err)
+	}
+
+	if out.String() != "h7" {
+		t.Fatalf("Unexpected output.\n Rec: %q\n Exp: %q", out.String(), "h7")
+	}
+}
+
+func TestVariableErrors(t *testing.T) {
+	src := ".var a\n.var a\n.var 1x\n.var b 2\n.array c\n.array d 0\n.equ e 1\n.array e 2\n.words a 1\n\tload f"
+	expected := []string{
+		"line 2:6: constant \"a\" already defined at line 1:1",
+		"line 3:6: expected a name for .var",
+		"line 4:8: unexpected size for .var; use .array",
+		"line 5:9: missing value",
+		"line 6:10: expected a positive size for .array",
+		"line 8:8: constant \"e\" already defined at line 7:1",
+		"line 10:7: undefined constant \"f\"",
+		"line 9:1: .words at line 9:1 (1-1) overlaps .var a at line 1:1 (1-1)",
+	}
+
+	_, err := Assemble(strings.NewReader(src))
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("Expected an ErrorList, received %v", err)
+	}
+
+	if len(list) != len(expected) {
+		t.Fatalf("Received %d errors; expected %d: %v", len(list), len(expected), list)
+	}
+
+	for i, exp := range expected {
+		if list[i].Error() != exp {
+			t.Logf("Received %q; expected %q", list[i].Error(), exp)
+			t.Fail()
+		}
+	}
+}
@@ -4,11 +4,17 @@ package main
 import (
 	"os"
 	"io"
-	//"strings"
+	"bufio"
+	"bytes"
+	"errors"
+	"strings"
 	"fmt"
 
 	"github.com/alexflint/go-arg"
 	ws "github.com/zorchenhimer/whitespace"
+	"github.com/zorchenhimer/whitespace/asm"
+	"github.com/zorchenhimer/whitespace/host"
+	ins "github.com/zorchenhimer/whitespace/instructions"
 )
 
 type Arguments struct {
@@ -16,6 +22,7 @@ type Arguments struct {
 	Output string `arg:"positional" help:"Output file.  Defaults to STDOUT."`
 	//Reader string `arg:"-r,--reader" help:"IO type.  Unimplemented."`
 	Debug bool `arg:"-d,--debug"`
+	Visible bool `arg:"-v,--visible" help:"Read the input as the visible notation, with S, T, and L or ·, →, and ¶.  Implied by a .wsv extension."`
 }
 
 func main() {
@@ -23,7 +30,14 @@ func main() {
 	arg.MustParse(args)
 	err := run(args)
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		var list asm.ErrorList
+		if errors.As(err, &list) {
+			for _, e := range list {
+				fmt.Fprintln(os.Stderr, e.Detail())
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		os.Exit(1)
 	}
 	fmt.Println("")
@@ -57,7 +71,34 @@ func run(args *Arguments) error {
 		defer output.Close()
 	}
 
-	e, err := ws.NewEngine(input)
+	// binary programs are recognized by their header
+	buffered := bufio.NewReader(input)
+	header, _ := buffered.Peek(len(ins.BinaryMagic))
+
+	var e *ws.Engine
+	if host.IsHost(args.Input) {
+		e, err = loadHost(args.Input, buffered)
+		if err != nil {
+			return err
+		}
+	} else if ins.IsBinary(header) {
+		var data []byte
+		data, err = io.ReadAll(buffered)
+		if err != nil {
+			return fmt.Errorf("Error reading input: %w", err)
+		}
+
+		var prog []ins.Instruction
+		prog, err = ins.DecodeBinary(data)
+		if err != nil {
+			return fmt.Errorf("Decode error: %w", err)
+		}
+		e, err = ws.NewEngineFromInstructions(prog)
+	} else if args.Visible || strings.HasSuffix(args.Input, ".wsv") {
+		e, err = ws.NewEngineFromReader(ws.NewVisibleReader(buffered))
+	} else {
+		e, err = ws.NewEngineFromReader(ws.NewReader(buffered))
+	}
 	if err != nil {
 		return fmt.Errorf("Engine error: %w", err)
 	}
@@ -70,3 +111,27 @@ func run(args *Arguments) error {
 
 	return nil
 }
+
+// loadHost returns an engine for the code blocks in a Markdown or Go file,
+// assembling them if they are assembly.
+func loadHost(filename string, input io.Reader) (*ws.Engine, error) {
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading input: %w", err)
+	}
+
+	src, lang, err := host.Load(filename, data)
+	if err != nil {
+		return nil, err
+	}
+
+	if lang == host.Whitespace {
+		return ws.NewEngineFromReader(ws.NewReader(bytes.NewReader(src)))
+	}
+
+	prog, err := asm.NewAssembler(bytes.NewReader(src), filename).Assemble()
+	if err != nil {
+		return nil, err
+	}
+	return ws.NewEngineFromInstructions(prog.Instructions)
+}
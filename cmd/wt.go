@@ -5,39 +5,168 @@ import (
 	"os"
 	"io"
 	"bytes"
+	"encoding/json"
+	"errors"
 	"fmt"
-	"strconv"
 	"strings"
 
 	"github.com/alexflint/go-arg"
 	ws "github.com/zorchenhimer/whitespace"
+	"github.com/zorchenhimer/whitespace/asm"
+	"github.com/zorchenhimer/whitespace/diff"
+	"github.com/zorchenhimer/whitespace/host"
 	ins "github.com/zorchenhimer/whitespace/instructions"
+	"github.com/zorchenhimer/whitespace/lint"
+	"github.com/zorchenhimer/whitespace/optimize"
 )
 
+// AsmArgs are the options for anything that assembles its input.
+type AsmArgs struct {
+	IncludePaths []string `arg:"-I,--include,separate" help:"Directory to search for included files.  Can be given more than once."`
+	Defines []string `arg:"-D,--define,separate" help:"Define NAME, or NAME=VALUE, before assembling.  Can be given more than once."`
+	Dialect string `arg:"--dialect" help:"Mnemonics to read and write assembly with: native or short" default:"native"`
+}
+
+func (opts AsmArgs) dialect() (*ins.Dialect, error) {
+	return ins.LookupDialect(opts.Dialect)
+}
+
 type Args struct {
 	Input string  `arg:"positional" help:"Input filename.  Defaults to STDIN."`
 	Output string `arg:"positional" help:"Output filename.  Defaults to STDOUT"`
 
 	Assembly bool `arg:"-a,--to-asm" help:"Translate to assembly"`
 	Wsp bool `arg:"-w,--to-wsp" help:"Translate to whitespace"`
+	Annotate bool `arg:"--annotate" help:"Write the assembly of each instruction in front of its whitespace.  Translating annotated whitespace to assembly gets the label names back."`
+	Visible bool `arg:"-v,--to-visible" help:"Translate to the visible notation, one instruction per line"`
+	FromVisible bool `arg:"--from-visible" help:"Read the input as the visible notation.  Implied by a .wsv extension."`
+	Glyphs bool `arg:"--glyphs" help:"Write the visible notation with · → and ¶ instead of S T and L"`
+	Binary bool `arg:"-b,--to-bin" help:"Translate to the binary .wsb format.  Input ending in .wsb is read as binary."`
+	JSON bool `arg:"-j,--to-json" help:"Translate to JSON with the source span of each instruction"`
+	FromJSON bool `arg:"--from-json" help:"Read the input as JSON.  Implied by a .json extension."`
+	Symbols string `arg:"-s,--symbols" help:"When assembling, write label names and their encodings to this file"`
+	Listing string `arg:"-l,--listing" help:"When assembling, write a listing of the source and its whitespace to this file"`
+	AsmArgs
+}
+
+type OptimizeArgs struct {
+	Input string  `arg:"positional" help:"Input filename.  Defaults to STDIN."`
+	Output string `arg:"positional" help:"Output filename.  Defaults to STDOUT"`
+
+	Assembly bool `arg:"-a,--to-asm" help:"Write assembly instead of whitespace"`
+	InlineSize int `arg:"--inline-size" help:"Largest subroutine body to inline.  Negative disables inlining." default:"8"`
+	AsmArgs
+}
+
+type ShrinkArgs struct {
+	Input string  `arg:"positional" help:"Input filename.  Defaults to STDIN."`
+	Output string `arg:"positional" help:"Output filename.  Defaults to STDOUT"`
+
+	Assembly bool `arg:"-a,--to-asm" help:"Write assembly instead of whitespace"`
+	AsmArgs
+}
+
+type EmbedArgs struct {
+	Input string  `arg:"positional" help:"Program to embed.  Assembled first if it ends in .wsa.  Defaults to STDIN."`
+	Output string `arg:"positional" help:"Output filename.  Defaults to STDOUT"`
+
+	Carrier string `arg:"-c,--carrier,required" help:"Text file to hide the program in"`
+	AsmArgs
+}
+
+type ExtractArgs struct {
+	Input string  `arg:"positional" help:"Text file with a program in it.  Defaults to STDIN."`
+	Output string `arg:"positional" help:"Output filename.  Defaults to STDOUT"`
+
+	Assembly bool `arg:"-a,--to-asm" help:"Write assembly instead of whitespace"`
+	AsmArgs
+}
+
+type DiffArgs struct {
+	Old string `arg:"positional,required" help:"Old program"`
+	New string `arg:"positional" help:"New program.  Not used with --textconv."`
+
+	Textconv bool `arg:"--textconv" help:"Write the assembly for one program with its labels named in order, for use as a git textconv filter"`
+	Context int `arg:"-U,--unified" help:"Lines of context around each change" default:"3"`
+	AsmArgs
+}
+
+type FmtArgs struct {
+	Input string  `arg:"positional" help:"Assembly to format.  Defaults to STDIN."`
+	Output string `arg:"positional" help:"Output filename.  Defaults to STDOUT"`
+
+	Check bool `arg:"--check" help:"Don't write anything.  Exit with 1 if the input isn't formatted."`
+	Dialect string `arg:"--dialect" help:"Mnemonics the assembly is written with: native or short" default:"native"`
+}
+
+type LintArgs struct {
+	Input string `arg:"positional" help:"Program to check.  Defaults to STDIN."`
+
+	Enable []string `arg:"-e,--enable,separate" help:"Run this rule even if it's off by default.  Can be given more than once."`
+	Disable []string `arg:"-x,--disable,separate" help:"Don't run this rule.  Can be given more than once."`
+	List bool `arg:"--list" help:"List the rules and exit"`
+	AsmArgs
+}
+
+// Subcommands are picked out before the main argument parsing because
+// go-arg doesn't allow positional arguments alongside subcommands.
+var subcommands = map[string]func(args []string) error{
+	"optimize": runOptimize,
+	"shrink":   runShrink,
+	"embed":    runEmbed,
+	"extract":  runExtract,
+	"diff":     runDiff,
+	"fmt":      runFmt,
+	"lint":     runLint,
 }
 
 func main() {
-	err := run()
+	var err error
+	if len(os.Args) > 1 && subcommands[os.Args[1]] != nil {
+		err = subcommands[os.Args[1]](os.Args[2:])
+	} else {
+		err = run()
+	}
+
+	if errors.Is(err, errDifferent) {
+		os.Exit(1)
+	}
+
 	if err != nil {
-		fmt.Fprintln(os.Stderr, err)
+		var list asm.ErrorList
+		if errors.As(err, &list) {
+			for _, e := range list {
+				fmt.Fprintln(os.Stderr, e.Detail())
+			}
+			if len(list) > 1 {
+				fmt.Fprintf(os.Stderr, "%d errors\n", len(list))
+			}
+		} else {
+			fmt.Fprintln(os.Stderr, err)
+		}
 		os.Exit(1)
 	}
 }
 
+// errDifferent is returned by diff when the programs are different, by fmt
+// --check when the input isn't formatted, and by lint when it finds
+// problems, to exit with 1 like diff(1).
+var errDifferent = errors.New("programs are different")
+
 type convertFunc func(reader io.Reader, writer io.Writer) error
 
 func run() error {
 	args := &Args{}
 	arg.MustParse(args)
 
-	if args.Assembly == args.Wsp && args.Assembly {
-		return fmt.Errorf("Cannot translate to both asm and wsp")
+	targets := 0
+	for _, t := range []bool{args.Assembly, args.Wsp, args.Visible, args.Binary, args.JSON} {
+		if t {
+			targets++
+		}
+	}
+	if targets > 1 {
+		return fmt.Errorf("Cannot translate to more than one of asm, wsp, visible, binary, and json")
 	}
 
 	var input io.Reader
@@ -56,6 +185,11 @@ func run() error {
 		input = inputfile
 	}
 
+	input, lang, hostData, err := loadHost(args.Input, input)
+	if err != nil {
+		return err
+	}
+
 	if args.Output == "" {
 		output = os.Stdout
 	} else {
@@ -63,29 +197,172 @@ func run() error {
 		defer func() {
 			err = os.WriteFile(args.Output, outputbuf.Bytes(), 0644)
 			if err != nil {
-				panic(fmt.Sprintf("error writing output file: %w", err))
+				panic(fmt.Sprintf("error writing output file: %v", err))
 			}
 		}()
 
 		output = outputbuf
 	}
 
+	dialect, err := args.dialect()
+	if err != nil {
+		return err
+	}
+
+	// assembles the input, writing the symbol and listing files
+	toProgram := func(reader io.Reader) (*asm.Program, error) {
+		prog, err := assemble(args.AsmArgs, args.Input, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		err = writeFile(args.Symbols, prog.WriteSymbols)
+		if err != nil {
+			return nil, err
+		}
+		return prog, writeFile(args.Listing, prog.WriteListing)
+	}
+
+	toWsp := func(reader io.Reader, writer io.Writer) error {
+		prog, err := toProgram(reader)
+		if err != nil {
+			return err
+		}
+
+		if args.Annotate {
+			return prog.WriteAnnotated(writer, dialect)
+		}
+
+		_, err = io.WriteString(writer, prog.Wsp())
+		return err
+	}
+
+	toAsm := func(reader io.Reader, writer io.Writer) error {
+		return disassemble(dialect, reader, writer)
+	}
+
+	visibleInput := args.FromVisible || strings.HasSuffix(args.Input, ".wsv")
+	binaryInput := strings.HasSuffix(args.Input, ".wsb")
+	jsonInput := args.FromJSON || strings.HasSuffix(args.Input, ".json")
+	wspInput := strings.HasSuffix(args.Input, ".wsp") || lang == host.Whitespace
+
+	// reads the input as whatever it is
+	readProgram := func(reader io.Reader) ([]ins.Instruction, error) {
+		switch {
+		case visibleInput:
+			return parseReader(ws.NewVisibleReader(reader))
+		case binaryInput:
+			return decodeBinary(reader)
+		case jsonInput:
+			return decodeJSON(reader)
+		case wspInput:
+			return parseReader(ws.NewReader(reader))
+		}
+
+		prog, err := toProgram(reader)
+		if err != nil {
+			return nil, err
+		}
+		return prog.Instructions, nil
+	}
+
+	toVisible := func(reader io.Reader, writer io.Writer) error {
+		prog, err := readProgram(reader)
+		if err != nil {
+			return err
+		}
+
+		_, err = writer.Write(encodeVisible(prog, args.Glyphs))
+		return err
+	}
+
+	toBinary := func(reader io.Reader, writer io.Writer) error {
+		prog, err := readProgram(reader)
+		if err != nil {
+			return err
+		}
+
+		data, err := ins.EncodeBinary(prog)
+		if err != nil {
+			return err
+		}
+
+		_, err = writer.Write(data)
+		return err
+	}
+
+	toJSON := func(reader io.Reader, writer io.Writer) error {
+		var p *ins.Program
+		if visibleInput || binaryInput || jsonInput {
+			prog, err := readProgram(reader)
+			if err != nil {
+				return err
+			}
+			p = ins.NewProgram(prog)
+		} else if wspInput {
+			cst, err := ws.ParseCST(reader)
+			if err != nil {
+				return fmt.Errorf("Parse error: %w", err)
+			}
+			p = cstSpans(args.Input, cst, hostData)
+		} else {
+			prog, err := toProgram(reader)
+			if err != nil {
+				return err
+			}
+			p = programSpans(prog)
+		}
+
+		data, err := json.MarshalIndent(p, "", "\t")
+		if err != nil {
+			return err
+		}
+
+		_, err = writer.Write(append(data, '\n'))
+		return err
+	}
+
+	// visible, binary, and JSON input go to whitespace unless asked otherwise
+	fromEncoded := func(reader io.Reader, writer io.Writer) error {
+		prog, err := readProgram(reader)
+		if err != nil {
+			return err
+		}
+
+		if args.Assembly {
+			_, err = writer.Write(encodeProgram(prog, dialect))
+		} else if args.Annotate {
+			err = asm.Annotate(writer, prog, nil, dialect)
+		} else {
+			_, err = writer.Write(encodeProgram(prog, nil))
+		}
+		return err
+	}
+
 	var cfunc convertFunc
 
-	if args.Assembly {
+	if args.Visible {
+		cfunc = toVisible
+	} else if args.Binary {
+		cfunc = toBinary
+	} else if args.JSON {
+		cfunc = toJSON
+	} else if visibleInput || binaryInput || jsonInput {
+		cfunc = fromEncoded
+	} else if args.Assembly {
 		cfunc = toAsm
 	} else if args.Wsp {
-		cfunc = toWhitespace
+		cfunc = toWsp
 
-	} else if strings.HasSuffix(args.Input, ".wsp") {
+	} else if wspInput {
 		// whitespace -> asm
 		cfunc = toAsm
 
 	} else if strings.HasSuffix(args.Input, ".wsa") {
 		// asm -> whitespace
-		cfunc = toWhitespace
+		cfunc = toWsp
 	} else {
-		cfunc = toWhitespace
+		cfunc = toWsp
 	}
 
 	return cfunc(input, output)
@@ -93,107 +370,575 @@ func run() error {
 	//return err
 }
 
-func toAsm(reader io.Reader, writer io.Writer) error {
-	parser := ws.NewParser(ws.NewReader(reader))
-	//parser.Debug = true
-	inst, err := parser.Parse()
+// disassemble writes the assembly for the whitespace in reader, with label
+// names from its annotations if it has any.
+func disassemble(dialect *ins.Dialect, reader io.Reader, writer io.Writer) error {
+	cst, err := ws.ParseCST(reader)
+	if err != nil {
+		return fmt.Errorf("Parse error: %w", err)
+	}
+
+	annotations := []string{}
+	for _, n := range cst.Nodes {
+		annotations = append(annotations, n.Leading)
+	}
+
+	prog := cst.Instructions()
+	return asm.Disassemble(writer, prog, asm.AnnotatedNames(prog, annotations), dialect)
+}
+
+// assemble assembles the source in reader.
+func assemble(opts AsmArgs, filename string, reader io.Reader) (*asm.Program, error) {
+	dialect, err := opts.dialect()
+	if err != nil {
+		return nil, err
+	}
 
-	if len(inst) != 0 {
-		for _, i := range inst {
-			fmt.Fprintln(writer, i.Asm())
+	a := asm.NewAssembler(reader, filename)
+	a.IncludePaths = opts.IncludePaths
+	a.Dialect = dialect
+	for _, def := range opts.Defines {
+		name, value, found := strings.Cut(def, "=")
+		if !found {
+			value = "1"
 		}
+		a.Defines[name] = value
 	}
 
+	return a.Assemble()
+}
+
+// writeFile writes to filename with write, if filename isn't empty.
+func writeFile(filename string, write func(w io.Writer) error) error {
+	if filename == "" {
+		return nil
+	}
+
+	buf := &bytes.Buffer{}
+	err := write(buf)
 	if err != nil {
-		return fmt.Errorf("Parse error: %w", err)
+		return err
+	}
+	return os.WriteFile(filename, buf.Bytes(), 0644)
+}
+
+// parseSubArgs behaves like arg.MustParse() for a subcommand.
+func parseSubArgs(name string, dest interface{}, args []string) {
+	p, err := arg.NewParser(arg.Config{Program: "wt "+name}, dest)
+	if err != nil {
+		panic(err)
+	}
+
+	err = p.Parse(args)
+	if err == arg.ErrHelp {
+		p.WriteHelp(os.Stdout)
+		os.Exit(0)
+	} else if err != nil {
+		p.Fail(err.Error())
+	}
+}
+
+func openInput(filename string) (io.ReadCloser, error) {
+	if filename == "" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	inputfile, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("error opening input file: %w", err)
+	}
+	return inputfile, nil
+}
+
+func writeOutput(filename string, data []byte) error {
+	if filename == "" {
+		_, err := os.Stdout.Write(data)
+		return err
+	}
+
+	err := os.WriteFile(filename, data, 0644)
+	if err != nil {
+		return fmt.Errorf("error writing output file: %w", err)
+	}
+	return nil
+}
+
+// loadHost returns only the code blocks of Markdown and Go files, their
+// language, and the whole file.  Other input is returned as is with no
+// language.
+func loadHost(filename string, input io.Reader) (io.Reader, string, []byte, error) {
+	if !host.IsHost(filename) {
+		return input, "", nil, nil
+	}
+
+	data, err := io.ReadAll(input)
+	if err != nil {
+		return nil, "", nil, fmt.Errorf("Unable to read input: %w", err)
+	}
+
+	src, lang, err := host.Load(filename, data)
+	if err != nil {
+		return nil, "", nil, err
+	}
+	return bytes.NewReader(src), lang, data, nil
+}
+
+// loadSource reads a whitespace program, assembling it first if the
+// filename looks like assembly.
+func loadSource(opts AsmArgs, filename string) ([]byte, error) {
+	input, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+
+	reader, lang, _, err := loadHost(filename, input)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasSuffix(filename, ".wsa") || lang == host.Assembly {
+		prog, err := assemble(opts, filename, reader)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(prog.Wsp()), nil
+	}
+
+	src, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read input: %w", err)
+	}
+	return src, nil
+}
+
+func parseSource(src []byte) ([]ins.Instruction, error) {
+	return parseReader(ws.NewReader(bytes.NewReader(src)))
+}
+
+func decodeBinary(reader io.Reader) ([]ins.Instruction, error) {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read input: %w", err)
+	}
+
+	prog, err := ins.DecodeBinary(data)
+	if err != nil {
+		return nil, fmt.Errorf("Decode error: %w", err)
+	}
+	return prog, nil
+}
+
+func decodeJSON(reader io.Reader) ([]ins.Instruction, error) {
+	p := &ins.Program{}
+	err := json.NewDecoder(reader).Decode(p)
+	if err != nil {
+		return nil, fmt.Errorf("Decode error: %w", err)
+	}
+	return p.Instructions, nil
+}
+
+// programSpans returns the assembled program with the source position of
+// each instruction in its spans.
+func programSpans(prog *asm.Program) *ins.Program {
+	p := ins.NewProgram(prog.Instructions)
+	for i, pos := range prog.Positions {
+		p.Spans[i].File = pos.Filename
+		p.Spans[i].Line = pos.Line
+		p.Spans[i].Column = pos.Column
+	}
+	return p
+}
+
+// cstSpans returns the parsed whitespace with the line and column of each
+// instruction in its spans.  Offsets in the CST are offsets in source, which
+// is the CST's own source if it's nil.
+func cstSpans(filename string, cst *ws.CST, source []byte) *ins.Program {
+	if source == nil {
+		source = cst.Bytes()
+	}
+
+	p := ins.NewProgram(cst.Instructions())
+	line, column, offset := 1, 1, 0
+	for i, n := range cst.Nodes {
+		for ; offset < n.Start; offset++ {
+			if source[offset] == '\n' {
+				line++
+				column = 1
+			} else {
+				column++
+			}
+		}
+
+		p.Spans[i].File = filename
+		p.Spans[i].Line = line
+		p.Spans[i].Column = column
+	}
+	return p
+}
+
+func parseReader(reader *ws.Reader) ([]ins.Instruction, error) {
+	prog, err := ws.NewParser(reader).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("Parse error: %w", err)
+	}
+	return prog, nil
+}
+
+// loadAny reads a program in any of the formats, by its extension.
+func loadAny(opts AsmArgs, filename string) ([]ins.Instruction, error) {
+	var decode func(io.Reader) ([]ins.Instruction, error)
+	switch {
+	case strings.HasSuffix(filename, ".wsv"):
+		decode = func(r io.Reader) ([]ins.Instruction, error) {
+			return parseReader(ws.NewVisibleReader(r))
+		}
+	case strings.HasSuffix(filename, ".wsb"):
+		decode = decodeBinary
+	case strings.HasSuffix(filename, ".json"):
+		decode = decodeJSON
+	default:
+		return loadProgram(opts, filename)
+	}
+
+	input, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer input.Close()
+	return decode(input)
+}
+
+// loadSpans reads a program in any of the formats, by its extension, with
+// the source position of each instruction where it's known and the names of
+// its labels.
+func loadSpans(opts AsmArgs, filename string) (*ins.Program, map[string]string, error) {
+	if strings.HasSuffix(filename, ".wsv") || strings.HasSuffix(filename, ".wsb") {
+		prog, err := loadAny(opts, filename)
+		if err != nil {
+			return nil, nil, err
+		}
+		return ins.NewProgram(prog), nil, nil
+	}
+
+	input, err := openInput(filename)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer input.Close()
+
+	if strings.HasSuffix(filename, ".json") {
+		p := &ins.Program{}
+		if err := json.NewDecoder(input).Decode(p); err != nil {
+			return nil, nil, fmt.Errorf("Decode error: %w", err)
+		}
+		// spans are optional, so fill in the byte offsets of any that
+		// are missing
+		offsets := ins.NewProgram(p.Instructions).Spans
+		if len(p.Spans) != len(p.Instructions) {
+			p.Spans = offsets
+		}
+		for i, span := range p.Spans {
+			if span == nil {
+				p.Spans[i] = offsets[i]
+			}
+		}
+		return p, nil, nil
+	}
+
+	reader, lang, hostData, err := loadHost(filename, input)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if strings.HasSuffix(filename, ".wsa") || lang == host.Assembly {
+		prog, err := assemble(opts, filename, reader)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return programSpans(prog), prog.LabelNames(), nil
+	}
+
+	cst, err := ws.ParseCST(reader)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Parse error: %w", err)
+	}
+
+	annotations := []string{}
+	for _, n := range cst.Nodes {
+		annotations = append(annotations, n.Leading)
+	}
+	prog := cst.Instructions()
+	return cstSpans(filename, cst, hostData), asm.AnnotatedNames(prog, annotations), nil
+}
+
+func loadProgram(opts AsmArgs, filename string) ([]ins.Instruction, error) {
+	src, err := loadSource(opts, filename)
+	if err != nil {
+		return nil, err
+	}
+	return parseSource(src)
+}
+
+// encodeProgram returns the whitespace for prog, or the assembly in dialect
+// if it isn't nil.
+func encodeProgram(prog []ins.Instruction, dialect *ins.Dialect) []byte {
+	buf := &bytes.Buffer{}
+	for _, i := range prog {
+		if dialect != nil {
+			fmt.Fprintln(buf, dialect.Asm(i))
+		} else {
+			buf.WriteString(i.Wsp())
+		}
+	}
+	return buf.Bytes()
+}
+
+// encodeVisible returns prog in the visible notation with each instruction
+// on its own line.
+func encodeVisible(prog []ins.Instruction, glyphs bool) []byte {
+	buf := &bytes.Buffer{}
+	for _, i := range prog {
+		if glyphs {
+			fmt.Fprintln(buf, ins.VisibleGlyphs(i.Wsp()))
+		} else {
+			fmt.Fprintln(buf, ins.Visible(i.Wsp()))
+		}
+	}
+	return buf.Bytes()
+}
+
+// writeProgram writes prog as whitespace, or as assembly in the dialect
+// from opts.
+func writeProgram(filename string, prog []ins.Instruction, assembly bool, opts AsmArgs) error {
+	var dialect *ins.Dialect
+	if assembly {
+		var err error
+		dialect, err = opts.dialect()
+		if err != nil {
+			return err
+		}
+	}
+	return writeOutput(filename, encodeProgram(prog, dialect))
+}
+
+func runOptimize(argv []string) error {
+	args := &OptimizeArgs{}
+	parseSubArgs("optimize", args, argv)
+
+	prog, err := loadProgram(args.AsmArgs, args.Input)
+	if err != nil {
+		return err
+	}
+
+	prog, report, err := optimize.Program(prog, optimize.Options{InlineSize: args.InlineSize})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, report)
+
+	return writeProgram(args.Output, prog, args.Assembly, args.AsmArgs)
+}
+
+func runShrink(argv []string) error {
+	args := &ShrinkArgs{}
+	parseSubArgs("shrink", args, argv)
+
+	src, err := loadSource(args.AsmArgs, args.Input)
+	if err != nil {
+		return err
+	}
+
+	prog, err := parseSource(src)
+	if err != nil {
+		return err
+	}
+
+	size := 0
+	for _, b := range src {
+		if b == ' ' || b == '\t' || b == '\n' {
+			size++
+		}
+	}
+
+	prog, report := optimize.Shrink(prog, size)
+	fmt.Fprintln(os.Stderr, report)
+
+	if !args.Assembly {
+		return writeOutput(args.Output, optimize.Encode(prog))
+	}
+	return writeProgram(args.Output, prog, args.Assembly, args.AsmArgs)
+}
+
+func runEmbed(argv []string) error {
+	args := &EmbedArgs{}
+	parseSubArgs("embed", args, argv)
+
+	src, err := loadSource(args.AsmArgs, args.Input)
+	if err != nil {
+		return err
+	}
+
+	carrier, err := os.ReadFile(args.Carrier)
+	if err != nil {
+		return fmt.Errorf("error reading carrier file: %w", err)
+	}
+
+	text, err := ws.Embed(carrier, src)
+	if err != nil {
+		return err
+	}
+	return writeOutput(args.Output, text)
+}
+
+func runExtract(argv []string) error {
+	args := &ExtractArgs{}
+	parseSubArgs("extract", args, argv)
+
+	input, err := openInput(args.Input)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	src, err := ws.Extract(input)
+	if err != nil {
+		return err
+	}
+
+	if !args.Assembly {
+		return writeOutput(args.Output, src)
+	}
+
+	prog, err := parseSource(src)
+	if err != nil {
+		return err
+	}
+	return writeProgram(args.Output, prog, args.Assembly, args.AsmArgs)
+}
+
+func runDiff(argv []string) error {
+	args := &DiffArgs{}
+	parseSubArgs("diff", args, argv)
+
+	dialect, err := args.dialect()
+	if err != nil {
+		return err
+	}
+
+	old, err := loadAny(args.AsmArgs, args.Old)
+	if err != nil {
+		return err
+	}
+
+	if args.Textconv {
+		return asm.Disassemble(os.Stdout, old, diff.Canonical(old, 1), dialect)
+	}
+
+	if args.New == "" {
+		return fmt.Errorf("diff needs two programs")
+	}
+
+	prog, err := loadAny(args.AsmArgs, args.New)
+	if err != nil {
+		return err
+	}
+
+	lines := diff.Programs(old, prog, dialect)
+	err = diff.WriteUnified(os.Stdout, args.Old, args.New, lines, args.Context)
+	if err != nil {
+		return err
+	}
+
+	if diff.Changed(lines) {
+		return errDifferent
 	}
 	return nil
 }
 
-func toWhitespace(reader io.Reader, writer io.Writer) error {
-	input, err := io.ReadAll(reader)
+func runFmt(argv []string) error {
+	args := &FmtArgs{}
+	parseSubArgs("fmt", args, argv)
+
+	dialect, err := ins.LookupDialect(args.Dialect)
+	if err != nil {
+		return err
+	}
+
+	input, err := openInput(args.Input)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+
+	src, err := io.ReadAll(input)
 	if err != nil {
 		return fmt.Errorf("Unable to read input: %w", err)
 	}
 
-	lines := strings.Split(string(input), "\n")
-
-	for i, l := range lines {
-		l = strings.ToLower(strings.TrimSpace(l))
-		// empty lines & comments
-		if len(l) == 0 || strings.HasPrefix(l, "#") {
-			continue
-		}
-
-		parts := strings.Split(l, " ")
-		switch parts[0] {
-		case "duplicate":
-			fmt.Fprint(writer, " \n ")
-		case "swap":
-			fmt.Fprint(writer, " \n\t")
-		case "discard":
-			fmt.Fprint(writer, " \n\n")
-		case "add":
-			fmt.Fprint(writer, "\t   ")
-		case "subtract":
-			fmt.Fprint(writer, "\t  \t")
-		case "multiply":
-			fmt.Fprint(writer, "\t  \n")
-		case "divide":
-			fmt.Fprint(writer, "\t \t ")
-		case "modulo":
-			fmt.Fprint(writer, "\t \t\t")
-		case "store":
-			fmt.Fprint(writer, "\t\t ")
-		case "load":
-			fmt.Fprint(writer, "\t\t\t")
-		case "return":
-			fmt.Fprint(writer, "\n\t\n")
-		case "stop":
-			fmt.Fprint(writer, "\n\n\n")
-		case "printchar":
-			fmt.Fprint(writer, "\t\n  ")
-		case "printnumber":
-			fmt.Fprint(writer, "\t\n \t")
-		case "readchar":
-			fmt.Fprint(writer, "\t\n\t ")
-		case "readnumber":
-			fmt.Fprint(writer, "\t\n\t\t")
-
-		default:
-			// everything with an argument
-			if len(parts) != 2 {
-				return fmt.Errorf("missing argument on line %d", i+1)
+	out := asm.Format(src, dialect)
+	if !args.Check {
+		return writeOutput(args.Output, out)
+	}
+
+	if !bytes.Equal(src, out) {
+		name := args.Input
+		if name == "" {
+			name = "<input>"
+		}
+		fmt.Fprintf(os.Stderr, "%s is not formatted\n", name)
+		return errDifferent
+	}
+	return nil
+}
+
+func runLint(argv []string) error {
+	args := &LintArgs{}
+	parseSubArgs("lint", args, argv)
+
+	if args.List {
+		for _, r := range lint.Rules {
+			off := ""
+			if !r.Default {
+				off = " (off by default)"
 			}
+			fmt.Printf("%-16s %s%s\n", r.Name, r.Description, off)
+		}
+		return nil
+	}
 
-			if parts[0] == "copy" || parts[0] == "push" {
-				n, err := strconv.ParseInt(parts[1], 10, 64)
-				if err != nil {
-					return fmt.Errorf("number parse error on line %d: %w", i+1, err)
-				}
-
-				if parts[0] == "copy" {
-					fmt.Fprint(writer, " \t ")
-				} else {
-					fmt.Fprint(writer, "  ")
-				}
-				fmt.Fprint(writer, ins.EncodeNumber(n))
-			} else {
-				switch parts[0] {
-				case "label":
-					fmt.Fprint(writer, "\n  ")
-				case "call":
-					fmt.Fprint(writer, "\n \t")
-				case "jump":
-					fmt.Fprint(writer, "\n \n")
-				case "jumpzero":
-					fmt.Fprint(writer, "\n\t ")
-				case "jumpminus":
-					fmt.Fprint(writer, "\n\t\t")
-				}
-				fmt.Fprint(writer, ins.EncodeLabel(parts[1]))
+	rules, err := lint.Select(args.Enable, args.Disable)
+	if err != nil {
+		return err
+	}
+
+	p, names, err := loadSpans(args.AsmArgs, args.Input)
+	if err != nil {
+		return err
+	}
+
+	name := args.Input
+	if name == "" {
+		name = "<input>"
+	}
+
+	problems := lint.Check(p.Instructions, names, rules)
+	for _, prob := range problems {
+		span := p.Spans[prob.Index]
+		if span.Line > 0 {
+			file := span.File
+			if file == "" {
+				file = name
 			}
+			fmt.Printf("%s:%d:%d: %s (%s)\n", file, span.Line, span.Column, prob.Message, prob.Rule)
+		} else {
+			fmt.Printf("%s: instruction %d: %s (%s)\n", name, prob.Index, prob.Message, prob.Rule)
 		}
 	}
+
+	if len(problems) > 0 {
+		return errDifferent
+	}
 	return nil
 }
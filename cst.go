@@ -0,0 +1,211 @@
+package whitespace
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// CST is a lossless parse of a whitespace program.  Every byte of the
+// source is in the Leading or Text of exactly one node, or in Trailing, so
+// writing an unchanged CST gives back the source byte for byte.
+type CST struct {
+	Nodes []*Node
+
+	// Text after the last instruction.
+	Trailing string
+
+	visible bool
+}
+
+// Node is an instruction and the source text that goes with it.
+type Node struct {
+	Instruction inst.Instruction
+
+	// Text between the previous instruction and this one.
+	Leading string
+
+	// Source of the instruction from its first whitespace character to its
+	// last, including any other text in between.
+	Text string
+
+	// The number or label as it was written, without the terminating
+	// newline.  Numbers keep their sign and any leading zeros.
+	Arg string
+
+	// Byte offsets of Text in the source.  Zero for added nodes.
+	Start int
+	End   int
+
+	wsp string // whitespace of the instruction when it was parsed
+}
+
+// ParseCST parses whitespace source into a CST.
+func ParseCST(r io.Reader) (*CST, error) {
+	return parseCST(r, false)
+}
+
+// ParseVisibleCST parses source in the visible notation into a CST.
+func ParseVisibleCST(r io.Reader) (*CST, error) {
+	return parseCST(r, true)
+}
+
+func parseCST(r io.Reader, visible bool) (*CST, error) {
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := &Reader{base: bufio.NewReader(bytes.NewReader(src)), visible: visible}
+	parser := NewParser(reader)
+	c := &CST{visible: visible}
+
+	prev := 0
+	for {
+		i, err := parser.next()
+		if err == io.EOF {
+			break
+		}
+
+		start := prev + c.skipText(src[prev:reader.offset])
+		if err != nil {
+			return nil, fmt.Errorf("offset %d: %w", start, err)
+		}
+
+		n := &Node{
+			Instruction: i,
+			Leading:     string(src[prev:start]),
+			Text:        string(src[start:reader.offset]),
+			Start:       start,
+			End:         reader.offset,
+			wsp:         i.Wsp(),
+		}
+
+		if prefix := argPrefix(i.Type()); prefix > 0 {
+			code := c.code(n.Text)
+			n.Arg = code[prefix : len(code)-1]
+		}
+
+		c.Nodes = append(c.Nodes, n)
+		prev = reader.offset
+	}
+
+	c.Trailing = string(src[prev:])
+	return c, nil
+}
+
+// argPrefix returns the length of the whitespace before the number or label
+// argument of a command, or zero if it doesn't have one.
+func argPrefix(c inst.Command) int {
+	switch c {
+	case inst.CmdPush:
+		return 2
+	case inst.CmdCopy, inst.CmdSlide, inst.CmdLabel, inst.CmdCall,
+		inst.CmdJump, inst.CmdJumpZero, inst.CmdJumpMinus:
+		return 3
+	}
+	return 0
+}
+
+func (c *CST) translate(r rune) (rune, bool) {
+	return (&Reader{visible: c.visible}).translate(r)
+}
+
+// skipText returns the number of bytes before the first whitespace
+// character in b.
+func (c *CST) skipText(b []byte) int {
+	for i := 0; i < len(b); {
+		r, n := utf8.DecodeRune(b[i:])
+		if _, ok := c.translate(r); ok {
+			return i
+		}
+		i += n
+	}
+	return len(b)
+}
+
+// code returns only the whitespace in text.
+func (c *CST) code(text string) string {
+	code := strings.Builder{}
+	for _, r := range text {
+		if ws, ok := c.translate(r); ok {
+			code.WriteRune(ws)
+		}
+	}
+	return code.String()
+}
+
+// Modified returns true if the node was added or its instruction has changed
+// since it was parsed.
+func (n *Node) Modified() bool {
+	return n.wsp == "" || n.Instruction.Wsp() != n.wsp
+}
+
+// Instructions returns the instruction of each node.
+func (c *CST) Instructions() []inst.Instruction {
+	prog := make([]inst.Instruction, len(c.Nodes))
+	for i, n := range c.Nodes {
+		prog[i] = n.Instruction
+	}
+	return prog
+}
+
+// Bytes returns the source for the CST.  Unmodified nodes are written as
+// they were parsed.  Modified nodes have their whitespace replaced in place,
+// keeping the other text around it.  Extra whitespace is added at the end
+// and any left over is removed.
+func (c *CST) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	for _, n := range c.Nodes {
+		buf.WriteString(n.Leading)
+		if n.Modified() {
+			buf.WriteString(c.rewrite(n.Text, n.Instruction.Wsp()))
+		} else {
+			buf.WriteString(n.Text)
+		}
+	}
+	buf.WriteString(c.Trailing)
+	return buf.Bytes()
+}
+
+// WriteTo writes the source for the CST to w.
+func (c *CST) WriteTo(w io.Writer) (int64, error) {
+	n, err := w.Write(c.Bytes())
+	return int64(n), err
+}
+
+// rewrite replaces the whitespace in text with code.
+func (c *CST) rewrite(text, code string) string {
+	out := strings.Builder{}
+	for _, r := range text {
+		if _, ok := c.translate(r); !ok {
+			out.WriteRune(r)
+		} else if code != "" {
+			out.WriteString(c.encode(code[0], r))
+			code = code[1:]
+		}
+	}
+
+	for i := 0; i < len(code); i++ {
+		out.WriteString(c.encode(code[i], 0))
+	}
+	return out.String()
+}
+
+// encode returns ws as it is written in the source, using glyphs if the
+// character it replaces was one.
+func (c *CST) encode(ws byte, replaced rune) string {
+	if !c.visible {
+		return string(ws)
+	}
+
+	if replaced == '·' || replaced == '→' || replaced == '¶' {
+		return inst.VisibleGlyphs(string(ws))
+	}
+	return inst.Visible(string(ws))
+}
@@ -0,0 +1,99 @@
+package whitespace
+
+import (
+	"strings"
+	"testing"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+func TestCST(t *testing.T) {
+	// push 1 with a leading zero, a comment inside printnumber, and a
+	// comment after stop
+	source := "push one:   \t\n\tprint\n \tnumber\n\n\nfin"
+
+	c, err := ParseCST(strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("ParseCST fail: %s", err)
+	}
+
+	if string(c.Bytes()) != source {
+		t.Fatalf("Unexpected round trip.\n Rec: %q\n Exp: %q", c.Bytes(), source)
+	}
+
+	expected := []struct {
+		leading string
+		text    string
+		arg     string
+		start   int
+		end     int
+	}{
+		{"push", " one:   \t\n", "  \t", 4, 14},
+		{"", "\tprint\n \t", "", 14, 23},
+		{"number", "\n\n\n", "", 29, 32},
+	}
+
+	if len(c.Nodes) != len(expected) {
+		t.Fatalf("Unexpected node count: %d", len(c.Nodes))
+	}
+
+	for i, exp := range expected {
+		n := c.Nodes[i]
+		if n.Leading != exp.leading || n.Text != exp.text || n.Arg != exp.arg || n.Start != exp.start || n.End != exp.end {
+			t.Logf("Unexpected node %d.\n Rec: %q %q %q %d %d\n Exp: %q %q %q %d %d", i,
+				n.Leading, n.Text, n.Arg, n.Start, n.End,
+				exp.leading, exp.text, exp.arg, exp.start, exp.end)
+			t.Fail()
+		}
+
+		if n.Modified() {
+			t.Logf("Node %d modified", i)
+			t.Fail()
+		}
+	}
+
+	if c.Trailing != "fin" {
+		t.Logf("Unexpected trailing text: %q", c.Trailing)
+		t.Fail()
+	}
+
+	// the comment stays where it is and the extra whitespace goes on the end
+	c.Nodes[0].Instruction = &inst.Push{Value: 5}
+	c.Nodes[1].Instruction = &inst.PrintChar{}
+	c.Nodes = append(c.Nodes, &Node{Instruction: &inst.Return{}})
+
+	edited := "push one:  \t \t\n\tprint\n  number\n\n\n\n\t\nfin"
+	if string(c.Bytes()) != edited {
+		t.Logf("Unexpected edit.\n Rec: %q\n Exp: %q", c.Bytes(), edited)
+		t.Fail()
+	}
+
+	_, err = ParseCST(strings.NewReader("a \tb"))
+	if err == nil || err.Error() != "offset 1: Bad second stack read" {
+		t.Logf("Unexpected error: %v", err)
+		t.Fail()
+	}
+}
+
+func TestVisibleCST(t *testing.T) {
+	source := "SSSTL ; push 1\nT\n¶·→ ; printnumber\nLLL"
+
+	c, err := ParseVisibleCST(strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("ParseVisibleCST fail: %s", err)
+	}
+
+	if string(c.Bytes()) != source {
+		t.Fatalf("Unexpected round trip.\n Rec: %q\n Exp: %q", c.Bytes(), source)
+	}
+
+	if len(c.Nodes) != 3 || c.Nodes[0].Arg != " \t" || c.Nodes[1].Text != "T\n¶·→" {
+		t.Fatalf("Unexpected nodes: %v", c.Nodes)
+	}
+
+	c.Nodes[1].Instruction = &inst.PrintChar{}
+	edited := "SSSTL ; push 1\nT\n¶·· ; printnumber\nLLL"
+	if string(c.Bytes()) != edited {
+		t.Fatalf("Unexpected edit.\n Rec: %q\n Exp: %q", c.Bytes(), edited)
+	}
+}
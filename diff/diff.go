@@ -0,0 +1,258 @@
+// Package diff compares whitespace programs.
+package diff
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+)
+
+// Op is what a line of a diff does.
+type Op int
+
+const (
+	Equal Op = iota
+	Delete
+	Insert
+)
+
+// Line is one line of a diff.  A and B are the indexes of the line in each
+// side, or -1 if it isn't in that side.
+type Line struct {
+	Op   Op
+	Text string
+	A    int
+	B    int
+}
+
+// Lines returns the shortest edit script that turns a into b, using the
+// linear space version of Myers' algorithm.  Within each run of changes the
+// deletes come before the inserts, as in a unified diff.
+func Lines(a, b []string) []Line {
+	lines := []Line{}
+	diffRange(a, b, 0, 0, &lines)
+
+	for start := 0; start < len(lines); {
+		if lines[start].Op == Equal {
+			start++
+			continue
+		}
+
+		end := start
+		for end < len(lines) && lines[end].Op != Equal {
+			end++
+		}
+
+		run := append([]Line{}, lines[start:end]...)
+		i := start
+		for _, op := range []Op{Delete, Insert} {
+			for _, l := range run {
+				if l.Op == op {
+					lines[i] = l
+					i++
+				}
+			}
+		}
+		start = end
+	}
+	return lines
+}
+
+// diffRange appends the edit script for a and b to lines.  aOff and bOff are
+// where a and b start in the whole sides.
+func diffRange(a, b []string, aOff, bOff int, lines *[]Line) {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	appendEqual(lines, a[:prefix], aOff, bOff)
+
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	ra, rb := a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+	rOffA, rOffB := aOff+prefix, bOff+prefix
+
+	switch {
+	case len(ra) == 0:
+		for i, t := range rb {
+			*lines = append(*lines, Line{Op: Insert, Text: t, A: -1, B: rOffB + i})
+		}
+	case len(rb) == 0:
+		for i, t := range ra {
+			*lines = append(*lines, Line{Op: Delete, Text: t, A: rOffA + i, B: -1})
+		}
+	default:
+		x, y, u, v := middleSnake(ra, rb)
+		diffRange(ra[:x], rb[:y], rOffA, rOffB, lines)
+		appendEqual(lines, ra[x:u], rOffA+x, rOffB+y)
+		diffRange(ra[u:], rb[v:], rOffA+u, rOffB+v, lines)
+	}
+
+	appendEqual(lines, a[len(a)-suffix:], aOff+len(a)-suffix, bOff+len(b)-suffix)
+}
+
+// appendEqual appends text as lines that are in both sides, starting at
+// line a in one and line b in the other.
+func appendEqual(lines *[]Line, text []string, a, b int) {
+	for i, t := range text {
+		*lines = append(*lines, Line{Op: Equal, Text: t, A: a + i, B: b + i})
+	}
+}
+
+// middleSnake returns the start and end of the snake in the middle of a
+// shortest edit script for a and b, by searching forward from the start and
+// backward from the end at the same time until the paths overlap.
+func middleSnake(a, b []string) (int, int, int, int) {
+	n, m := len(a), len(b)
+	delta := n - m
+	max := (n + m + 1) / 2
+	offset := max + 1
+
+	// furthest x on each diagonal, going forward from the start and going
+	// backward from the end with x and y counted from the end
+	vf := make([]int, 2*max+3)
+	vb := make([]int, 2*max+3)
+
+	for d := 0; d <= max; d++ {
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vf[offset+k-1] < vf[offset+k+1]) {
+				x = vf[offset+k+1]
+			} else {
+				x = vf[offset+k-1] + 1
+			}
+
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			vf[offset+k] = x
+
+			if back := delta - k; delta%2 != 0 && back >= -(d-1) && back <= d-1 && x+vb[offset+back] >= n {
+				return startX, startY, x, y
+			}
+		}
+
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && vb[offset+k-1] < vb[offset+k+1]) {
+				x = vb[offset+k+1]
+			} else {
+				x = vb[offset+k-1] + 1
+			}
+
+			y := x - k
+			startX, startY := x, y
+			for x < n && y < m && a[n-1-x] == b[m-1-y] {
+				x++
+				y++
+			}
+			vb[offset+k] = x
+
+			if forward := delta - k; delta%2 == 0 && forward >= -d && forward <= d && x+vf[offset+forward] >= n {
+				return n - x, m - y, n - startX, m - startY
+			}
+		}
+	}
+
+	// not reached, the paths always overlap by the middle
+	return 0, 0, 0, 0
+}
+
+// Changed returns true if any of the lines aren't Equal.
+func Changed(lines []Line) bool {
+	for _, l := range lines {
+		if l.Op != Equal {
+			return true
+		}
+	}
+	return false
+}
+
+// WriteUnified writes the lines as a unified diff with the given number of
+// lines of context around each change.  Nothing is written if there are no
+// changes.
+func WriteUnified(w io.Writer, nameA, nameB string, lines []Line, context int) error {
+	if !Changed(lines) {
+		return nil
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "--- %s\n+++ %s\n", nameA, nameB)
+
+	// number of lines of each side before each line of the diff
+	posA := make([]int, len(lines)+1)
+	posB := make([]int, len(lines)+1)
+	for i, l := range lines {
+		posA[i+1], posB[i+1] = posA[i], posB[i]
+		if l.Op != Insert {
+			posA[i+1]++
+		}
+		if l.Op != Delete {
+			posB[i+1]++
+		}
+	}
+
+	for start := 0; start < len(lines); {
+		first := start
+		for first < len(lines) && lines[first].Op == Equal {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// extend the hunk until there are more than two contexts' worth
+		// of unchanged lines
+		end := first
+		for i := first; i < len(lines) && i-end <= 2*context; i++ {
+			if lines[i].Op != Equal {
+				end = i + 1
+			}
+		}
+
+		from := first - context
+		if from < start {
+			from = start
+		}
+		to := end + context
+		if to > len(lines) {
+			to = len(lines)
+		}
+
+		fmt.Fprintf(bw, "@@ -%s +%s @@\n",
+			hunkRange(posA[from], posA[to]-posA[from]),
+			hunkRange(posB[from], posB[to]-posB[from]))
+		for _, l := range lines[from:to] {
+			switch l.Op {
+			case Equal:
+				bw.WriteString(" ")
+			case Delete:
+				bw.WriteString("-")
+			case Insert:
+				bw.WriteString("+")
+			}
+			bw.WriteString(l.Text + "\n")
+		}
+
+		start = to
+	}
+	return bw.Flush()
+}
+
+// hunkRange returns the range of a hunk for one side.  An empty range
+// starts at the line before it.
+func hunkRange(before, count int) string {
+	switch count {
+	case 0:
+		return fmt.Sprintf("%d,0", before)
+	case 1:
+		return fmt.Sprint(before + 1)
+	}
+	return fmt.Sprintf("%d,%d", before+1, count)
+}
@@ -0,0 +1,184 @@
+package diff
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+func TestLines(t *testing.T) {
+	tests := []struct {
+		a, b  string
+		edits int
+	}{
+		{"", "", 0},
+		{"a b c", "a b c", 0},
+		{"a b c", "", 3},
+		{"", "a b", 2},
+		{"a b c a b b a", "c b a b a c", 5},
+		{"x a b", "a b y", 2},
+	}
+
+	for _, tst := range tests {
+		lines := Lines(strings.Fields(tst.a), strings.Fields(tst.b))
+
+		// both sides have to come back out in order
+		a, b := []string{}, []string{}
+		edits := 0
+		for _, l := range lines {
+			if l.Op != Insert {
+				if l.A != len(a) {
+					t.Fatalf("Line out of order in a: %v", l)
+				}
+				a = append(a, l.Text)
+			}
+			if l.Op != Delete {
+				if l.B != len(b) {
+					t.Fatalf("Line out of order in b: %v", l)
+				}
+				b = append(b, l.Text)
+			}
+			if l.Op != Equal {
+				edits++
+			}
+		}
+
+		if strings.Join(a, " ") != tst.a || strings.Join(b, " ") != tst.b || edits != tst.edits {
+			t.Logf("Unexpected diff of %q and %q: %q %q with %d edits", tst.a, tst.b, a, b, edits)
+			t.Fail()
+		}
+	}
+}
+
+func TestLinesOrder(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected string
+	}{
+		{"a b c", "x y z", "-a -b -c +x +y +z"},
+		{"a b c d", "x b y z d", "-a +x  b -c +y +z  d"},
+		{"1 2 3", "2 3 4 1 2", "-1  2  3 +4 +1 +2"},
+	}
+
+	for _, tst := range tests {
+		ops := []string{}
+		for _, l := range Lines(strings.Fields(tst.a), strings.Fields(tst.b)) {
+			ops = append(ops, []string{" ", "-", "+"}[l.Op]+l.Text)
+		}
+
+		if strings.Join(ops, " ") != tst.expected {
+			t.Logf("Unexpected diff of %q and %q.\n Rec: %s\n Exp: %s", tst.a, tst.b, strings.Join(ops, " "), tst.expected)
+			t.Fail()
+		}
+	}
+}
+
+func TestLinesLarge(t *testing.T) {
+	// every other line is changed
+	a, b := []string{}, []string{}
+	for i := 0; i < 4000; i++ {
+		a = append(a, fmt.Sprint(i))
+		if i%2 == 0 {
+			b = append(b, fmt.Sprint(i))
+		} else {
+			b = append(b, fmt.Sprint(-i))
+		}
+	}
+
+	count := map[Op]int{}
+	for i, l := range Lines(a, b) {
+		if l.Op == Equal && (a[l.A] != l.Text || b[l.B] != l.Text) {
+			t.Fatalf("Line %d doesn't match: %v", i, l)
+		}
+		count[l.Op]++
+	}
+
+	if count[Equal] != 2000 || count[Delete] != 2000 || count[Insert] != 2000 {
+		t.Fatalf("Unexpected diff: %v", count)
+	}
+}
+
+func TestUnified(t *testing.T) {
+	a := strings.Fields("1 2 3 4 5 6 7 8 9 10 11 12 13 14 15")
+	b := strings.Fields("1 2 3 four 5 6 7 8 9 10 11 12 13 14 15 16")
+
+	buf := &strings.Builder{}
+	if err := WriteUnified(buf, "a", "b", Lines(a, b), 2); err != nil {
+		t.Fatalf("WriteUnified() error: %s", err)
+	}
+
+	expected := `--- a
++++ b
+@@ -2,5 +2,5 @@
+ 2
+ 3
+-4
++four
+ 5
+ 6
+@@ -14,2 +14,3 @@
+ 14
+ 15
++16
+`
+	if buf.String() != expected {
+		t.Fatalf("Unexpected diff.\n Rec: %q\n Exp: %q", buf.String(), expected)
+	}
+
+	buf.Reset()
+	if err := WriteUnified(buf, "a", "b", Lines(a, a), 3); err != nil || buf.Len() != 0 {
+		t.Fatalf("Unexpected diff of the same lines: %q %v", buf.String(), err)
+	}
+}
+
+func TestPrograms(t *testing.T) {
+	// the labels are renamed and an instruction is added
+	a := []inst.Instruction{
+		&inst.Call{Value: " "},
+		&inst.Stop{},
+		&inst.Label{Value: " "},
+		&inst.Push{Value: 1},
+		&inst.JumpZero{Value: "\t"},
+		&inst.Jump{Value: " "},
+		&inst.Label{Value: "\t"},
+		&inst.Return{},
+	}
+	b := []inst.Instruction{
+		&inst.Call{Value: "\t\t"},
+		&inst.Stop{},
+		&inst.Label{Value: "\t\t"},
+		&inst.Push{Value: 1},
+		&inst.PrintNumber{},
+		&inst.JumpZero{Value: "  "},
+		&inst.Jump{Value: "\t\t"},
+		&inst.Label{Value: "  "},
+		&inst.Return{},
+	}
+
+	buf := &strings.Builder{}
+	err := WriteUnified(buf, "a.wsp", "b.wsp", Programs(a, b, inst.Native), 1)
+	if err != nil {
+		t.Fatalf("WriteUnified() error: %s", err)
+	}
+
+	expected := `--- a.wsp
++++ b.wsp
+@@ -4,2 +4,3 @@
+ push 1
++printnumber
+ jumpzero L2
+`
+	if buf.String() != expected {
+		t.Fatalf("Unexpected diff.\n Rec: %q\n Exp: %q", buf.String(), expected)
+	}
+
+	// a label that only a has
+	a = append(a, &inst.Jump{Value: "\t\t\t"})
+	lines := Programs(a, b, inst.Native)
+	last := lines[len(lines)-1]
+	if last.Op != Delete || last.Text != "jump L3" {
+		t.Fatalf("Unexpected last line: %v", last)
+	}
+}
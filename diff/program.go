@@ -0,0 +1,107 @@
+package diff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/zorchenhimer/whitespace/asm"
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// Programs returns the diff of the assembly for two programs.  Labels are
+// matched up by where they are used, so renaming a label isn't a change.
+// Labels are written as L1, L2, and so on in the order they first appear
+// in b, followed by the labels in a that don't match one in b.
+func Programs(a, b []inst.Instruction, dialect *inst.Dialect) []Line {
+	namesB := Canonical(b, 1)
+	namesA := matchLabels(a, b, namesB)
+
+	return Lines(disassemble(a, namesA, dialect), disassemble(b, namesB, dialect))
+}
+
+// Canonical returns names for the labels of prog in the order they first
+// appear, starting at L<first>.
+func Canonical(prog []inst.Instruction, first int) map[string]string {
+	names := make(map[string]string)
+	for _, i := range prog {
+		if fc, ok := i.(inst.FlowControl); ok {
+			if _, exist := names[fc.Label()]; !exist {
+				names[fc.Label()] = fmt.Sprintf("L%d", first+len(names))
+			}
+		}
+	}
+	return names
+}
+
+// matchLabels names the labels of a after the labels of b that they line up
+// with when labels are ignored.  Each label in a gets the name of the label
+// in b it lines up with most often.  Labels without a match get new names.
+func matchLabels(a, b []inst.Instruction, namesB map[string]string) map[string]string {
+	type pair struct{ a, b string }
+	votes := make(map[pair]int)
+	order := []pair{}
+
+	for _, l := range Lines(shapes(a), shapes(b)) {
+		if l.Op != Equal {
+			continue
+		}
+
+		fa, ok := a[l.A].(inst.FlowControl)
+		if !ok {
+			continue
+		}
+		p := pair{fa.Label(), b[l.B].(inst.FlowControl).Label()}
+		if votes[p] == 0 {
+			order = append(order, p)
+		}
+		votes[p]++
+	}
+
+	sort.SliceStable(order, func(i, j int) bool {
+		return votes[order[i]] > votes[order[j]]
+	})
+
+	names := make(map[string]string)
+	used := make(map[string]bool)
+	for _, p := range order {
+		if _, exist := names[p.a]; exist || used[p.b] {
+			continue
+		}
+		names[p.a] = namesB[p.b]
+		used[p.b] = true
+	}
+
+	next := len(namesB) + 1
+	for _, i := range a {
+		if fc, ok := i.(inst.FlowControl); ok {
+			if _, exist := names[fc.Label()]; !exist {
+				names[fc.Label()] = fmt.Sprintf("L%d", next)
+				next++
+			}
+		}
+	}
+	return names
+}
+
+// shapes returns each instruction without its label.
+func shapes(prog []inst.Instruction) []string {
+	lines := make([]string, len(prog))
+	for idx, i := range prog {
+		lines[idx] = inst.Native.Mnemonic(i.Type())
+		if _, ok := i.(inst.FlowControl); !ok {
+			lines[idx] = i.Asm()
+		}
+	}
+	return lines
+}
+
+func disassemble(prog []inst.Instruction, names map[string]string, dialect *inst.Dialect) []string {
+	if len(prog) == 0 {
+		return nil
+	}
+
+	buf := &strings.Builder{}
+	asm.Disassemble(buf, prog, names, dialect)
+	return strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+}
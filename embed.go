@@ -0,0 +1,86 @@
+package whitespace
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Embed weaves the whitespace of prog into carrier.  The words and lines of
+// the carrier are kept and its whitespace is replaced:
+//
+//   - Each space or tab between words is the next space or tab of the
+//     program, or a no-break space (U+00A0) if the program needs a newline
+//     first.
+//   - Each line ends with the rest of the program up to its next newline, so
+//     most of the program is trailing whitespace.
+//   - Once the program runs out, lines end in "push 0", which is never
+//     reached if the program ends with stop.
+//   - Any program left after the last line is added to the end.
+//
+// Anything in prog that isn't whitespace is ignored.
+func Embed(carrier, prog []byte) ([]byte, error) {
+	code, err := io.ReadAll(NewReader(bytes.NewReader(prog)))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	out := &bytes.Buffer{}
+	lines := strings.SplitAfter(string(carrier), "\n")
+	for _, line := range lines {
+		newline := strings.HasSuffix(line, "\n")
+		line = strings.TrimSuffix(line, "\n")
+		cr := strings.HasSuffix(line, "\r")
+		line = strings.TrimRight(strings.TrimSuffix(line, "\r"), " \t")
+
+		for _, r := range line {
+			switch {
+			case r != ' ' && r != '\t':
+				out.WriteRune(r)
+			case len(code) > 0 && code[0] != '\n':
+				out.WriteByte(code[0])
+				code = code[1:]
+			default:
+				out.WriteRune('\u00A0')
+			}
+		}
+
+		if !newline {
+			break
+		}
+
+		end := bytes.IndexByte(code, '\n')
+		if end == -1 {
+			code = append(code, embedPadding...)
+			end = bytes.IndexByte(code, '\n')
+		}
+
+		out.Write(code[:end])
+		if cr {
+			out.WriteByte('\r')
+		}
+		out.WriteByte('\n')
+		code = code[end+1:]
+	}
+	out.Write(code)
+
+	return out.Bytes(), nil
+}
+
+// embedPadding is push 0.
+const embedPadding = "   \n"
+
+// Extract returns the whitespace hidden in text, checking that it parses.
+func Extract(text io.Reader) ([]byte, error) {
+	code, err := io.ReadAll(NewReader(text))
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	_, err = NewParser(NewReader(bytes.NewReader(code))).Parse()
+	if err != nil {
+		return nil, fmt.Errorf("Parse error: %w", err)
+	}
+	return code, nil
+}
@@ -0,0 +1,63 @@
+package whitespace
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestEmbed(t *testing.T) {
+	tests := []struct {
+		name     string
+		carrier  string
+		prog     string
+		expected string
+	}{
+		// push 1, printnumber, stop
+		{"spread", "a b\nc d\ne\n", "   \t\n\t\n \t\n\n\n", "a b  \t\nc\td\ne \t\n\n\n"},
+		{"no-break space", "x y\nz\nw\n", "\n\n\n", "x\u00a0y\nz\nw\n"},
+		{"padding", "a\nb\nc", "\t   ", "a\t      \nb   \nc"},
+		{"crlf", "a b\r\nc\r\n", "   \t\n", "a b  \t\r\nc   \r\n"},
+		{"comments", "a  b\n", "push   \t\n", "a  b \t\n"},
+	}
+
+	for _, tst := range tests {
+		out, err := Embed([]byte(tst.carrier), []byte(tst.prog))
+		if err != nil {
+			t.Logf("[%s] Embed error: %s", tst.name, err)
+			t.Fail()
+			continue
+		}
+
+		if string(out) != tst.expected {
+			t.Logf("[%s] Unexpected output.\n Rec: %q\n Exp: %q", tst.name, out, tst.expected)
+			t.Fail()
+			continue
+		}
+
+		code, err := Extract(bytes.NewReader(out))
+		if err != nil {
+			t.Logf("[%s] Extract error: %s", tst.name, err)
+			t.Fail()
+			continue
+		}
+
+		// the program followed by nothing but push 0
+		prog := strings.Map(func(r rune) rune {
+			if r == ' ' || r == '\t' || r == '\n' {
+				return r
+			}
+			return -1
+		}, tst.prog)
+		if !strings.HasPrefix(string(code), prog) || strings.ReplaceAll(string(code[len(prog):]), embedPadding, "") != "" {
+			t.Logf("[%s] Unexpected program.\n Rec: %q\n Exp: %q", tst.name, code, prog)
+			t.Fail()
+		}
+	}
+
+	_, err := Extract(strings.NewReader("a \tb"))
+	if err == nil || err.Error() != "Parse error: Bad second stack read" {
+		t.Logf("Unexpected error: %v", err)
+		t.Fail()
+	}
+}
@@ -16,12 +16,24 @@ type Engine struct {
 }
 
 func NewEngine(reader io.Reader) (*Engine, error) {
-	p := NewParser(NewReader(reader))
+	return NewEngineFromReader(NewReader(reader))
+}
+
+// NewEngineFromReader is like NewEngine, but takes a Reader so the program
+// can be in the visible notation.
+func NewEngineFromReader(reader *Reader) (*Engine, error) {
+	p := NewParser(reader)
 	instlst, err := p.Parse()
 	if err != nil {
 		return nil, err
 	}
 
+	return NewEngineFromInstructions(instlst)
+}
+
+// NewEngineFromInstructions returns an engine for a program that has
+// already been parsed or decoded.
+func NewEngineFromInstructions(instlst []inst.Instruction) (*Engine, error) {
 	ast, err := getAst(instlst)
 	if err != nil {
 		return nil, err
@@ -139,7 +151,7 @@ func (e *Engine) Run(input io.Reader, output io.Writer) error {
 			}
 
 		case inst.CmdReturn:
-			e.ast = e.calls.Pop()
+			e.ast = e.calls.Pop().Next
 			branched = true
 
 		case inst.CmdStop:
@@ -4,6 +4,8 @@ import (
 	"testing"
 	"strings"
 	//"io"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
 )
 
 
@@ -28,3 +30,156 @@ func TestEngineBasic(t *testing.T) {
 	}
 	t.Logf("output: %q", out.String())
 }
+
+func TestEngineCallReturn(t *testing.T) {
+	// return goes to the instruction after the call, not the call again
+	prog := []inst.Instruction{
+		&inst.Push{Value: 1},
+		&inst.Call{Value: " "},
+		&inst.Push{Value: 2},
+		&inst.Call{Value: " "},
+		&inst.Stop{},
+		&inst.Label{Value: " "},
+		&inst.PrintNumber{},
+		&inst.Return{},
+	}
+
+	source := ""
+	for _, i := range prog {
+		source += i.Wsp()
+	}
+
+	e, err := NewEngine(strings.NewReader(source))
+	if err != nil {
+		t.Fatalf("Engine creation fail: %s", err)
+	}
+
+	out := &strings.Builder{}
+	err = e.Run(nil, out)
+	if err != nil {
+		t.Fatalf("Run fail: %s", err)
+	}
+
+	if out.String() != "12" {
+		t.Fatalf("Unexpected output.\n Rec: %q\n Exp: %q", out.String(), "12")
+	}
+}
+
+func TestFlowControlRoundTrip(t *testing.T) {
+	// labels are terminated by a newline, so they can be followed by
+	// anything
+	prog := []inst.Instruction{
+		&inst.Label{Value: " \t"},
+		&inst.Call{Value: " \t"},
+		&inst.Jump{Value: "\t"},
+		&inst.JumpZero{Value: "  "},
+		&inst.JumpMinus{Value: "\t\t"},
+		&inst.Label{Value: ""},
+		&inst.Push{Value: 5},
+		&inst.Return{},
+		&inst.Stop{},
+	}
+
+	source := ""
+	for _, i := range prog {
+		source += i.Wsp()
+	}
+
+	parsed, err := NewParser(NewReader(strings.NewReader(source))).Parse()
+	if err != nil {
+		t.Fatalf("Parse fail: %s", err)
+	}
+
+	if len(parsed) != len(prog) {
+		t.Fatalf("Unexpected instruction count: %d; expected %d", len(parsed), len(prog))
+	}
+
+	for i := range prog {
+		if parsed[i].Type() != prog[i].Type() || parsed[i].Wsp() != prog[i].Wsp() {
+			t.Logf("Unexpected instruction %d.\n Rec: %q\n Exp: %q", i, parsed[i].Wsp(), prog[i].Wsp())
+			t.Fail()
+		}
+	}
+}
+
+func TestEngineBinary(t *testing.T) {
+	prog := []inst.Instruction{
+		&inst.Push{Value: 3},
+		&inst.Call{Value: " "},
+		&inst.Stop{},
+		&inst.Label{Value: " "},
+		&inst.Duplicate{},
+		&inst.PrintNumber{},
+		&inst.Push{Value: -1},
+		&inst.Add{},
+		&inst.Duplicate{},
+		&inst.JumpZero{Value: "\t"},
+		&inst.Jump{Value: " "},
+		&inst.Label{Value: "\t"},
+		&inst.Return{},
+	}
+
+	data, err := inst.EncodeBinary(prog)
+	if err != nil {
+		t.Fatalf("EncodeBinary fail: %s", err)
+	}
+
+	decoded, err := inst.DecodeBinary(data)
+	if err != nil {
+		t.Fatalf("DecodeBinary fail: %s", err)
+	}
+
+	e, err := NewEngineFromInstructions(decoded)
+	if err != nil {
+		t.Fatalf("Engine creation fail: %s", err)
+	}
+
+	out := &strings.Builder{}
+	err = e.Run(nil, out)
+	if err != nil {
+		t.Fatalf("Run fail: %s", err)
+	}
+
+	if out.String() != "321" {
+		t.Fatalf("Unexpected output.\n Rec: %q\n Exp: %q", out.String(), "321")
+	}
+}
+
+// loadProgram has a thousand labels with their own numbers, for comparing
+// how long it takes to load whitespace and .wsb.
+func loadProgram() []inst.Instruction {
+	prog := []inst.Instruction{}
+	for n := 0; n < 1000; n++ {
+		l := inst.NthLabel(n)
+		prog = append(prog, &inst.Label{Value: l}, &inst.Push{Value: int64(n)}, &inst.PrintNumber{}, &inst.JumpZero{Value: l})
+	}
+	return append(prog, &inst.Stop{})
+}
+
+func BenchmarkLoadWhitespace(b *testing.B) {
+	source := ""
+	for _, i := range loadProgram() {
+		source += i.Wsp()
+	}
+	b.SetBytes(int64(len(source)))
+
+	for n := 0; n < b.N; n++ {
+		if _, err := NewParser(NewReader(strings.NewReader(source))).Parse(); err != nil {
+			b.Fatalf("Parse fail: %s", err)
+		}
+	}
+}
+
+func BenchmarkLoadBinary(b *testing.B) {
+	data, err := inst.EncodeBinary(loadProgram())
+	if err != nil {
+		b.Fatalf("EncodeBinary fail: %s", err)
+	}
+	b.SetBytes(int64(len(data)))
+
+	for n := 0; n < b.N; n++ {
+		if _, err := inst.DecodeBinary(data); err != nil {
+			b.Fatalf("DecodeBinary fail: %s", err)
+		}
+	}
+}
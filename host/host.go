@@ -0,0 +1,212 @@
+// Package host finds whitespace programs inside other files, like the code
+// blocks of a Markdown document or a comment in Go source.
+package host
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// Languages a region can be marked with.
+const (
+	Whitespace = "whitespace"
+	Assembly   = "wsa"
+)
+
+// tags are the words that mark a region, and the language of each.
+var tags = map[string]string{
+	"whitespace": Whitespace,
+	"ws":         Whitespace,
+	"wsp":        Whitespace,
+	"wsa":        Assembly,
+}
+
+// Region is the contents of a marked part of a host file.
+type Region struct {
+	Lang string
+
+	// Byte offsets of the contents in the host file, from the start of the
+	// line after the opening marker to the start of the closing marker's
+	// line.
+	Start int
+	End   int
+
+	// Line of the host file that the contents start on.
+	Line int
+}
+
+// IsHost returns true if filename is a kind of file that programs are found
+// in.
+func IsHost(filename string) bool {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".md", ".markdown", ".go":
+		return true
+	}
+	return false
+}
+
+// Find returns the marked regions in a host file.  Go files use block
+// comments that start with /*whitespace or /*wsa on a line of their own and
+// end with */ on a line of its own.  Anything else is read as Markdown,
+// where fenced code blocks with a whitespace, ws, wsp, or wsa info string
+// are regions.
+func Find(filename string, data []byte) []Region {
+	if strings.ToLower(filepath.Ext(filename)) == ".go" {
+		return findGo(data)
+	}
+	return findMarkdown(data)
+}
+
+// hostLine is a line of a host file without its newline.
+type hostLine struct {
+	text  string
+	start int // byte offset
+}
+
+func splitLines(data []byte) []hostLine {
+	lines := []hostLine{}
+	start := 0
+	for start < len(data) {
+		end := bytes.IndexByte(data[start:], '\n')
+		if end == -1 {
+			end = len(data) - start
+		}
+		lines = append(lines, hostLine{
+			text:  strings.TrimRight(string(data[start:start+end]), "\r"),
+			start: start,
+		})
+		start += end + 1
+	}
+	return lines
+}
+
+// findMarkdown finds fenced code blocks.  A fence is three or more
+// backticks or tildes at the start of a line and is closed by a fence of the
+// same character that is at least as long.  Blocks that aren't closed run to
+// the end of the document.
+func findMarkdown(data []byte) []Region {
+	regions := []Region{}
+	lines := splitLines(data)
+
+	for i := 0; i < len(lines); i++ {
+		fence, info := openingFence(lines[i].text)
+		if fence == "" {
+			continue
+		}
+
+		r := Region{Start: len(data), End: len(data), Line: i + 2}
+		if i+1 < len(lines) {
+			r.Start = lines[i+1].start
+		}
+
+		for i++; i < len(lines); i++ {
+			if isClosingFence(lines[i].text, fence) {
+				r.End = lines[i].start
+				break
+			}
+		}
+
+		if lang, ok := tags[strings.ToLower(info)]; ok {
+			r.Lang = lang
+			regions = append(regions, r)
+		}
+	}
+	return regions
+}
+
+// openingFence returns the fence and the first word of the info string.
+func openingFence(line string) (string, string) {
+	if !strings.HasPrefix(line, "```") && !strings.HasPrefix(line, "~~~") {
+		return "", ""
+	}
+
+	n := len(line) - len(strings.TrimLeft(line, line[:1]))
+	fields := strings.Fields(line[n:])
+	if len(fields) == 0 {
+		return line[:n], ""
+	}
+	return line[:n], fields[0]
+}
+
+func isClosingFence(line, fence string) bool {
+	line = strings.TrimRight(line, " \t")
+	return strings.HasPrefix(line, fence) && strings.Trim(line, fence[:1]) == ""
+}
+
+// findGo finds block comments marked with a language.
+func findGo(data []byte) []Region {
+	regions := []Region{}
+	lines := splitLines(data)
+
+	for i := 0; i < len(lines); i++ {
+		text := strings.TrimSpace(lines[i].text)
+		if !strings.HasPrefix(text, "/*") {
+			continue
+		}
+
+		lang, ok := tags[strings.ToLower(strings.TrimSpace(text[2:]))]
+		if !ok {
+			continue
+		}
+
+		r := Region{Lang: lang, Start: len(data), End: len(data), Line: i + 2}
+		if i+1 < len(lines) {
+			r.Start = lines[i+1].start
+		}
+
+		for i++; i < len(lines); i++ {
+			if strings.TrimSpace(lines[i].text) == "*/" {
+				r.End = lines[i].start
+				break
+			}
+		}
+		regions = append(regions, r)
+	}
+	return regions
+}
+
+// Load returns the program in the marked regions of a host file and its
+// language.  Everything outside of the regions is blanked out instead of
+// removed, so a position in the program is the same position in the host
+// file.  For assembly every line outside of the regions is emptied, and for
+// whitespace every space, tab, and newline outside of them is replaced with
+// a period.  All of the regions must be in the same language.
+func Load(filename string, data []byte) ([]byte, string, error) {
+	regions := Find(filename, data)
+	if len(regions) == 0 {
+		return nil, "", fmt.Errorf("no whitespace or wsa code blocks in %s", displayName(filename))
+	}
+
+	lang := regions[0].Lang
+	for _, r := range regions {
+		if r.Lang != lang {
+			return nil, "", fmt.Errorf("%s:%d: %s code block after %s code blocks", displayName(filename), r.Line-1, r.Lang, lang)
+		}
+	}
+
+	src := make([]byte, len(data))
+	for i, b := range data {
+		switch {
+		case lang == Assembly && b != '\n':
+			src[i] = ' '
+		case lang == Whitespace && (b == ' ' || b == '\t' || b == '\n'):
+			src[i] = '.'
+		default:
+			src[i] = b
+		}
+	}
+
+	for _, r := range regions {
+		copy(src[r.Start:r.End], data[r.Start:r.End])
+	}
+	return src, lang, nil
+}
+
+func displayName(filename string) string {
+	if filename == "" {
+		return "<input>"
+	}
+	return filename
+}
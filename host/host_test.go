@@ -0,0 +1,125 @@
+package host
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/zorchenhimer/whitespace/asm"
+)
+
+func TestFindMarkdown(t *testing.T) {
+	doc := "# Title\n" +
+		"```wsa\n" +
+		"push 1\n" +
+		"```\n" +
+		"````go\n" +
+		"```wsa\n" +
+		"not this\n" +
+		"````\n" +
+		"~~~ Whitespace extra\n" +
+		" \t\n" +
+		"~~~~\n" +
+		"```wsp\n" +
+		"to the end\n"
+
+	expected := []Region{
+		{Assembly, 15, 22, 3},
+		{Whitespace, 75, 78, 10},
+		{Whitespace, 90, 101, 13},
+	}
+
+	regions := Find("doc.md", []byte(doc))
+	if len(regions) != len(expected) {
+		t.Fatalf("Unexpected regions: %v", regions)
+	}
+
+	for i, r := range regions {
+		if r != expected[i] {
+			t.Logf("Unexpected region %d.\n Rec: %v\n Exp: %v", i, r, expected[i])
+			t.Fail()
+		}
+	}
+}
+
+func TestFindGo(t *testing.T) {
+	src := "package main\n" +
+		"\n" +
+		"/* not a program */\n" +
+		"/*wsa\n" +
+		"\tpush 1\n" +
+		"*/\n" +
+		"\t/* whitespace\n" +
+		"\n\n\n" +
+		"\t*/\n"
+
+	expected := []Region{
+		{Assembly, 40, 48, 5},
+		{Whitespace, 66, 69, 8},
+	}
+
+	regions := Find("main.go", []byte(src))
+	if len(regions) != len(expected) {
+		t.Fatalf("Unexpected regions: %v", regions)
+	}
+
+	for i, r := range regions {
+		if r != expected[i] {
+			t.Logf("Unexpected region %d.\n Rec: %v\n Exp: %v", i, r, expected[i])
+			t.Fail()
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	doc := "Some text.\n" +
+		"```wsa\n" +
+		"push 1\n" +
+		"```\n" +
+		"More text.\n" +
+		"```wsa\n" +
+		"  jump nowhere\n" +
+		"```\n"
+
+	src, lang, err := Load("doc.md", []byte(doc))
+	if err != nil {
+		t.Fatalf("Load() error: %s", err)
+	}
+
+	expected := "          \n      \npush 1\n   \n          \n      \n  jump nowhere\n   \n"
+	if lang != Assembly || string(src) != expected {
+		t.Fatalf("Unexpected source.\n Rec: %s %q\n Exp: %s %q", lang, src, Assembly, expected)
+	}
+
+	// errors are in the host file
+	_, err = asm.NewAssembler(strings.NewReader(string(src)), "doc.md").Assemble()
+	if err == nil || err.Error() != `doc.md:7:8: undefined label "nowhere"` {
+		t.Logf("Unexpected error: %v", err)
+		t.Fail()
+	}
+
+	doc = "a b\n```ws\n \t\n```\nc\td\n"
+	src, lang, err = Load("doc.md", []byte(doc))
+	expected = "a.b.```ws. \t\n```.c.d."
+	if err != nil || lang != Whitespace || string(src) != expected {
+		t.Logf("Unexpected source.\n Rec: %s %q %v\n Exp: %s %q", lang, src, err, Whitespace, expected)
+		t.Fail()
+	}
+
+	bad := []struct {
+		filename string
+		src      string
+		error    string
+	}{
+		{"doc.md", "```go\n```\n", "no whitespace or wsa code blocks in doc.md"},
+		{"doc.md", "```wsa\n```\n```ws\n```\n", "doc.md:3: whitespace code block after wsa code blocks"},
+		{"main.go", "```wsa\n```\n", "no whitespace or wsa code blocks in main.go"},
+	}
+
+	for _, tst := range bad {
+		_, _, err := Load(tst.filename, []byte(tst.src))
+		if err == nil || err.Error() != tst.error {
+			t.Logf("Unexpected error for %q.\n Rec: %v\n Exp: %s", tst.src, err, tst.error)
+			t.Fail()
+		}
+	}
+}
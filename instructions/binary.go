@@ -0,0 +1,337 @@
+package instructions
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+)
+
+// The .wsb binary format is laid out as:
+//
+//	magic     "WSB" and a version byte
+//	labels    uvarint count, then for each label:
+//	            uvarint length in bits, the bits packed high bit first with
+//	            1 for tab and 0 for space, and uvarint index of the label's
+//	            definition plus one, or zero if it isn't defined
+//	program   uvarint count, then for each instruction an opcode byte
+//	            followed by a zigzag varint for numbers or a uvarint index
+//	            into the labels for labels
+//	checksum  CRC-32 (IEEE) of everything before it, little endian
+const (
+	BinaryMagic   = "WSB"
+	BinaryVersion = 1
+)
+
+// Opcodes are fixed so the format doesn't change along with Command.
+var binaryOpcodes = map[Command]byte{
+	CmdPush:        0x01,
+	CmdDuplicate:   0x02,
+	CmdCopy:        0x03,
+	CmdSwap:        0x04,
+	CmdDiscard:     0x05,
+	CmdSlide:       0x06,
+	CmdAdd:         0x07,
+	CmdSubtract:    0x08,
+	CmdMultiply:    0x09,
+	CmdDivide:      0x0A,
+	CmdModulo:      0x0B,
+	CmdStore:       0x0C,
+	CmdLoad:        0x0D,
+	CmdLabel:       0x0E,
+	CmdCall:        0x0F,
+	CmdJump:        0x10,
+	CmdJumpZero:    0x11,
+	CmdJumpMinus:   0x12,
+	CmdReturn:      0x13,
+	CmdStop:        0x14,
+	CmdPrintChar:   0x15,
+	CmdPrintNumber: 0x16,
+	CmdReadChar:    0x17,
+	CmdReadNumber:  0x18,
+}
+
+// IsBinary returns true if data starts like the .wsb format.
+func IsBinary(data []byte) bool {
+	return bytes.HasPrefix(data, []byte(BinaryMagic))
+}
+
+// numberValue returns the number argument of push, copy, and slide.
+func numberValue(i Instruction) (int64, bool) {
+	switch c := i.(type) {
+	case *Push:
+		return c.Value, true
+	case Push:
+		return c.Value, true
+	case *Copy:
+		return c.Value, true
+	case Copy:
+		return c.Value, true
+	case *Slide:
+		return c.Value, true
+	case Slide:
+		return c.Value, true
+	}
+	return 0, false
+}
+
+// newInstruction returns the instruction for a command and its argument.
+func newInstruction(c Command, n int64, label string) Instruction {
+	switch c {
+	case CmdPush:
+		return &Push{Value: n}
+	case CmdDuplicate:
+		return &Duplicate{}
+	case CmdCopy:
+		return &Copy{Value: n}
+	case CmdSwap:
+		return &Swap{}
+	case CmdDiscard:
+		return &Discard{}
+	case CmdSlide:
+		return &Slide{Value: n}
+	case CmdAdd:
+		return &Add{}
+	case CmdSubtract:
+		return &Subtract{}
+	case CmdMultiply:
+		return &Multiply{}
+	case CmdDivide:
+		return &Divide{}
+	case CmdModulo:
+		return &Modulo{}
+	case CmdStore:
+		return &Store{}
+	case CmdLoad:
+		return &Load{}
+	case CmdLabel:
+		return &Label{Value: label}
+	case CmdCall:
+		return &Call{Value: label}
+	case CmdJump:
+		return &Jump{Value: label}
+	case CmdJumpZero:
+		return &JumpZero{Value: label}
+	case CmdJumpMinus:
+		return &JumpMinus{Value: label}
+	case CmdReturn:
+		return &Return{}
+	case CmdStop:
+		return &Stop{}
+	case CmdPrintChar:
+		return &PrintChar{}
+	case CmdPrintNumber:
+		return &PrintNumber{}
+	case CmdReadChar:
+		return &ReadChar{}
+	case CmdReadNumber:
+		return &ReadNumber{}
+	}
+	return nil
+}
+
+// EncodeBinary returns the program in the .wsb format.
+func EncodeBinary(prog []Instruction) ([]byte, error) {
+	labels := []string{}
+	index := make(map[string]int)
+	targets := []int{}
+
+	for idx, i := range prog {
+		fc, ok := i.(FlowControl)
+		if !ok {
+			continue
+		}
+
+		l := fc.Label()
+		if _, exist := index[l]; !exist {
+			index[l] = len(labels)
+			labels = append(labels, l)
+			targets = append(targets, 0)
+		}
+		if i.Type() == CmdLabel && targets[index[l]] == 0 {
+			targets[index[l]] = idx + 1
+		}
+	}
+
+	buf := &bytes.Buffer{}
+	buf.WriteString(BinaryMagic)
+	buf.WriteByte(BinaryVersion)
+
+	writeUvarint(buf, uint64(len(labels)))
+	for i, l := range labels {
+		writeUvarint(buf, uint64(len(l)))
+		buf.Write(packLabel(l))
+		writeUvarint(buf, uint64(targets[i]))
+	}
+
+	writeUvarint(buf, uint64(len(prog)))
+	for idx, i := range prog {
+		op, ok := binaryOpcodes[i.Type()]
+		if !ok {
+			return nil, fmt.Errorf("instruction %d: unknown command %s", idx, CmdString(i.Type()))
+		}
+		buf.WriteByte(op)
+
+		if n, ok := numberValue(i); ok {
+			b := make([]byte, binary.MaxVarintLen64)
+			buf.Write(b[:binary.PutVarint(b, n)])
+		} else if fc, ok := i.(FlowControl); ok {
+			writeUvarint(buf, uint64(index[fc.Label()]))
+		}
+	}
+
+	sum := make([]byte, 4)
+	binary.LittleEndian.PutUint32(sum, crc32.ChecksumIEEE(buf.Bytes()))
+	buf.Write(sum)
+
+	return buf.Bytes(), nil
+}
+
+// DecodeBinary returns the program in data, which is in the .wsb format.
+func DecodeBinary(data []byte) ([]Instruction, error) {
+	if !IsBinary(data) || len(data) < len(BinaryMagic)+1+4 {
+		return nil, fmt.Errorf("not a .wsb file")
+	}
+
+	body := data[:len(data)-4]
+	sum := binary.LittleEndian.Uint32(data[len(data)-4:])
+	if crc32.ChecksumIEEE(body) != sum {
+		return nil, fmt.Errorf("bad .wsb checksum")
+	}
+
+	if v := body[len(BinaryMagic)]; v != BinaryVersion {
+		return nil, fmt.Errorf("unsupported .wsb version %d", v)
+	}
+
+	r := bytes.NewReader(body[len(BinaryMagic)+1:])
+
+	count, err := readCount(r, "label")
+	if err != nil {
+		return nil, err
+	}
+
+	labels := make([]string, count)
+	targets := make([]uint64, count)
+	for i := range labels {
+		bits, err := readCount(r, "label length")
+		if err != nil {
+			return nil, err
+		}
+
+		packed := make([]byte, (bits+7)/8)
+		if _, err := io.ReadFull(r, packed); err != nil {
+			return nil, errTruncated
+		}
+		labels[i] = unpackLabel(packed, bits)
+
+		targets[i], err = binary.ReadUvarint(r)
+		if err != nil {
+			return nil, errTruncated
+		}
+	}
+
+	count, err = readCount(r, "instruction")
+	if err != nil {
+		return nil, err
+	}
+
+	commands := make(map[byte]Command)
+	for c, op := range binaryOpcodes {
+		commands[op] = c
+	}
+
+	prog := make([]Instruction, 0, count)
+	for idx := 0; idx < count; idx++ {
+		op, err := r.ReadByte()
+		if err != nil {
+			return nil, errTruncated
+		}
+
+		c, ok := commands[op]
+		if !ok {
+			return nil, fmt.Errorf("instruction %d: unknown opcode 0x%02X", idx, op)
+		}
+
+		var n int64
+		label := ""
+		switch c {
+		case CmdPush, CmdCopy, CmdSlide:
+			n, err = binary.ReadVarint(r)
+			if err != nil {
+				return nil, errTruncated
+			}
+		case CmdLabel, CmdCall, CmdJump, CmdJumpZero, CmdJumpMinus:
+			l, err := binary.ReadUvarint(r)
+			if err != nil {
+				return nil, errTruncated
+			}
+			if l >= uint64(len(labels)) {
+				return nil, fmt.Errorf("instruction %d: label %d out of range", idx, l)
+			}
+			label = labels[l]
+		}
+
+		prog = append(prog, newInstruction(c, n, label))
+	}
+
+	if r.Len() != 0 {
+		return nil, fmt.Errorf("%d extra bytes after the program", r.Len())
+	}
+
+	for i, t := range targets {
+		if t == 0 {
+			continue
+		}
+
+		if t > uint64(len(prog)) || prog[t-1].Type() != CmdLabel || prog[t-1].(FlowControl).Label() != labels[i] {
+			return nil, fmt.Errorf("label %d doesn't match its target %d", i, t-1)
+		}
+	}
+
+	return prog, nil
+}
+
+var errTruncated = errors.New("truncated .wsb file")
+
+func writeUvarint(buf *bytes.Buffer, n uint64) {
+	b := make([]byte, binary.MaxVarintLen64)
+	buf.Write(b[:binary.PutUvarint(b, n)])
+}
+
+// readCount reads a uvarint that can't be more than the bytes left in r,
+// so a corrupt count can't allocate too much.
+func readCount(r *bytes.Reader, what string) (int, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return 0, errTruncated
+	}
+	if n > uint64(r.Len())*8 {
+		return 0, fmt.Errorf("%s count %d is too large", what, n)
+	}
+	return int(n), nil
+}
+
+// packLabel packs a label into bits, high bit first, with 1 for tab.
+func packLabel(l string) []byte {
+	packed := make([]byte, (len(l)+7)/8)
+	for i := 0; i < len(l); i++ {
+		if l[i] == '\t' {
+			packed[i/8] |= 0x80 >> (i % 8)
+		}
+	}
+	return packed
+}
+
+func unpackLabel(packed []byte, bits int) string {
+	l := make([]byte, bits)
+	for i := range l {
+		if packed[i/8]&(0x80>>(i%8)) != 0 {
+			l[i] = '\t'
+		} else {
+			l[i] = ' '
+		}
+	}
+	return string(l)
+}
@@ -0,0 +1,93 @@
+package instructions
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"strings"
+	"testing"
+)
+
+// countdown prints 321.
+var countdown = []Instruction{
+	&Push{Value: 3},
+	&Call{Value: " "},
+	&Stop{},
+	&Label{Value: " "},
+	&Duplicate{},
+	&PrintNumber{},
+	&Push{Value: -1},
+	&Add{},
+	&Duplicate{},
+	&JumpZero{Value: "\t"},
+	&Jump{Value: " "},
+	&Label{Value: "\t"},
+	&Return{},
+}
+
+func TestBinaryRoundTrip(t *testing.T) {
+	source := ""
+	for _, i := range countdown {
+		source += i.Wsp()
+	}
+
+	data, err := EncodeBinary(countdown)
+	if err != nil {
+		t.Fatalf("EncodeBinary fail: %s", err)
+	}
+
+	decoded, err := DecodeBinary(data)
+	if err != nil {
+		t.Fatalf("DecodeBinary fail: %s", err)
+	}
+
+	wsp := &strings.Builder{}
+	for _, i := range decoded {
+		wsp.WriteString(i.Wsp())
+	}
+	if wsp.String() != source {
+		t.Fatalf("Unexpected round trip.\n Rec: %q\n Exp: %q", wsp.String(), source)
+	}
+
+	// header, labels s and t, and 13 instructions with 7 operands
+	if len(data) != 4+1+6+1+13+7+4 {
+		t.Logf("Unexpected size: %d", len(data))
+		t.Fail()
+	}
+}
+
+func TestBinaryErrors(t *testing.T) {
+	data, err := EncodeBinary(countdown)
+	if err != nil {
+		t.Fatalf("EncodeBinary fail: %s", err)
+	}
+
+	corrupt := func(f func(b []byte) []byte) []byte {
+		b := append([]byte{}, data...)
+		return f(b)
+	}
+	resum := func(b []byte) []byte {
+		sum := crc32.ChecksumIEEE(b[:len(b)-4])
+		binary.LittleEndian.PutUint32(b[len(b)-4:], sum)
+		return b
+	}
+
+	bad := []struct {
+		data  []byte
+		error string
+	}{
+		{[]byte(" \t\n"), "not a .wsb file"},
+		{corrupt(func(b []byte) []byte { b[8] ^= 1; return b }), "bad .wsb checksum"},
+		{corrupt(func(b []byte) []byte { b[3] = 2; return resum(b) }), "unsupported .wsb version 2"},
+		{corrupt(func(b []byte) []byte { b[12] = 0x7F; return resum(b) }), "instruction 0: unknown opcode 0x7F"},
+		{corrupt(func(b []byte) []byte { return resum(append(b[:len(b)-6], 0, 0, 0, 0)) }), "truncated .wsb file"},
+		{corrupt(func(b []byte) []byte { b[7] = 3; return resum(b) }), "label 0 doesn't match its target 2"},
+	}
+
+	for i, tst := range bad {
+		_, err := DecodeBinary(tst.data)
+		if err == nil || err.Error() != tst.error {
+			t.Logf("[%d] Received error %v; expected %q", i, err, tst.error)
+			t.Fail()
+		}
+	}
+}
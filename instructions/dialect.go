@@ -0,0 +1,126 @@
+package instructions
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Dialect is a set of mnemonics used by an assembler.
+type Dialect struct {
+	Name string
+
+	// Mnemonics that differ from the Native dialect.
+	Mnemonics map[Command]string
+
+	// Write label definitions as "name:" instead of "label name".
+	LabelColon bool
+}
+
+// Native is the dialect written by Instruction.Asm().
+var Native = &Dialect{
+	Name: "native",
+	Mnemonics: map[Command]string{
+		CmdPush:        "push",
+		CmdDuplicate:   "duplicate",
+		CmdCopy:        "copy",
+		CmdSwap:        "swap",
+		CmdDiscard:     "discard",
+		CmdSlide:       "slide",
+		CmdAdd:         "add",
+		CmdSubtract:    "subtract",
+		CmdMultiply:    "multiply",
+		CmdDivide:      "divide",
+		CmdModulo:      "modulo",
+		CmdStore:       "store",
+		CmdLoad:        "load",
+		CmdLabel:       "label",
+		CmdCall:        "call",
+		CmdJump:        "jump",
+		CmdJumpZero:    "jumpzero",
+		CmdJumpMinus:   "jumpminus",
+		CmdReturn:      "return",
+		CmdStop:        "stop",
+		CmdPrintChar:   "printchar",
+		CmdPrintNumber: "printnumber",
+		CmdReadChar:    "readchar",
+		CmdReadNumber:  "readnumber",
+	},
+}
+
+// Short is the terse dialect used by many other whitespace tools.
+var Short = &Dialect{
+	Name: "short",
+	Mnemonics: map[Command]string{
+		CmdDuplicate:   "dup",
+		CmdSubtract:    "sub",
+		CmdMultiply:    "mul",
+		CmdDivide:      "div",
+		CmdModulo:      "mod",
+		CmdLoad:        "retrieve",
+		CmdJumpZero:    "jz",
+		CmdJumpMinus:   "jn",
+		CmdReturn:      "ret",
+		CmdStop:        "end",
+		CmdPrintChar:   "outc",
+		CmdPrintNumber: "outn",
+		CmdReadChar:    "inc",
+		CmdReadNumber:  "inn",
+	},
+	LabelColon: true,
+}
+
+// Dialects by name.
+var Dialects = map[string]*Dialect{
+	Native.Name: Native,
+	Short.Name:  Short,
+}
+
+// LookupDialect returns the dialect with the given name.
+func LookupDialect(name string) (*Dialect, error) {
+	if d, ok := Dialects[strings.ToLower(name)]; ok {
+		return d, nil
+	}
+
+	names := []string{}
+	for n := range Dialects {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return nil, fmt.Errorf("unknown dialect %q; expected one of %s", name, strings.Join(names, ", "))
+}
+
+// Mnemonic returns the mnemonic for a command.
+func (d *Dialect) Mnemonic(c Command) string {
+	if m, ok := d.Mnemonics[c]; ok {
+		return m
+	}
+	return Native.Mnemonics[c]
+}
+
+// Command returns the command for a mnemonic in this dialect.  Mnemonics
+// are not case sensitive.
+func (d *Dialect) Command(mnemonic string) (Command, bool) {
+	mnemonic = strings.ToLower(mnemonic)
+	for c, m := range d.Mnemonics {
+		if m == mnemonic {
+			return c, true
+		}
+	}
+	return CmdInvalid, false
+}
+
+// Asm returns the assembly for an instruction in this dialect.
+func (d *Dialect) Asm(i Instruction) string {
+	asm := i.Asm()
+	_, operand, _ := strings.Cut(asm, " ")
+
+	if i.Type() == CmdLabel && d.LabelColon {
+		return operand + ":"
+	}
+
+	if operand == "" {
+		return d.Mnemonic(i.Type())
+	}
+	return d.Mnemonic(i.Type()) + " " + operand
+}
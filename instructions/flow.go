@@ -0,0 +1,61 @@
+package instructions
+
+// IsBranch returns true for instructions that reference a label without
+// defining it.
+func IsBranch(i Instruction) bool {
+	switch i.Type() {
+	case CmdCall, CmdJump, CmdJumpZero, CmdJumpMinus:
+		return true
+	}
+	return false
+}
+
+// FallsThrough returns true if the instruction after i can run after it.
+func FallsThrough(i Instruction) bool {
+	switch i.Type() {
+	case CmdJump, CmdReturn, CmdStop:
+		return false
+	}
+	return true
+}
+
+// Reachable marks every instruction in prog that can execute when starting
+// from start.  Calls are assumed to return, and the subroutine they go to is
+// only followed if calls is true.  Branches go to the first definition of
+// their label.
+func Reachable(prog []Instruction, start int, calls bool) []bool {
+	labels := make(map[string]int)
+	for idx, i := range prog {
+		if i.Type() != CmdLabel {
+			continue
+		}
+		if _, ok := labels[i.(FlowControl).Label()]; !ok {
+			labels[i.(FlowControl).Label()] = idx
+		}
+	}
+
+	seen := make([]bool, len(prog))
+	queue := []int{start}
+
+	for len(queue) > 0 {
+		idx := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if idx < 0 || idx >= len(prog) || seen[idx] {
+			continue
+		}
+		seen[idx] = true
+
+		i := prog[idx]
+		if IsBranch(i) && (calls || i.Type() != CmdCall) {
+			if target, ok := labels[i.(FlowControl).Label()]; ok {
+				queue = append(queue, target)
+			}
+		}
+
+		if FallsThrough(i) {
+			queue = append(queue, idx+1)
+		}
+	}
+
+	return seen
+}
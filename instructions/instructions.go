@@ -243,11 +243,11 @@ func (c JumpMinus) Type() Command { return CmdJumpMinus }
 func (c Return)    Type() Command { return CmdReturn }
 func (c Stop)      Type() Command { return CmdStop }
 
-func (c Label)     Wsp() string { return "\n  "+c.Value }
-func (c Call)      Wsp() string { return "\n \t"+c.Value }
-func (c Jump)      Wsp() string { return "\n \n"+c.Value }
-func (c JumpZero)  Wsp() string { return "\n\t "+c.Value }
-func (c JumpMinus) Wsp() string { return "\n\t\t"+c.Value }
+func (c Label)     Wsp() string { return "\n  "+c.Value+"\n" }
+func (c Call)      Wsp() string { return "\n \t"+c.Value+"\n" }
+func (c Jump)      Wsp() string { return "\n \n"+c.Value+"\n" }
+func (c JumpZero)  Wsp() string { return "\n\t "+c.Value+"\n" }
+func (c JumpMinus) Wsp() string { return "\n\t\t"+c.Value+"\n" }
 func (c Return)    Wsp() string { return "\n\t\n" }
 func (c Stop)      Wsp() string { return "\n\n\n" }
 
@@ -0,0 +1,228 @@
+package instructions
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// JSONVersion is the version of the JSON program schema.
+const JSONVersion = 1
+
+// Each instruction is a JSON object with its mnemonic in "op".  Push, copy,
+// and slide have a "number", and flow control has a "label" written with s
+// for space and t for tab.
+//
+//	{"op": "push", "number": -3}
+//	{"op": "jumpzero", "label": "st"}
+//	{"op": "add"}
+type jsonInstruction struct {
+	Op     string  `json:"op"`
+	Number *int64  `json:"number,omitempty"`
+	Label  *string `json:"label,omitempty"`
+	Span   *Span   `json:"span,omitempty"`
+}
+
+// Span is where an instruction came from.  Start and End are the byte
+// offsets of the instruction in the whitespace.  File, Line, and Column are
+// only set if the source is known.
+type Span struct {
+	File   string `json:"file,omitempty"`
+	Line   int    `json:"line,omitempty"`
+	Column int    `json:"column,omitempty"`
+	Start  int    `json:"start"`
+	End    int    `json:"end"`
+}
+
+func toJSON(i Instruction) jsonInstruction {
+	j := jsonInstruction{Op: Native.Mnemonic(i.Type())}
+	if n, ok := numberValue(i); ok {
+		j.Number = &n
+	} else if fc, ok := i.(FlowControl); ok {
+		l := DecodeLabel(fc.Label())
+		j.Label = &l
+	}
+	return j
+}
+
+func fromJSON(j jsonInstruction) (Instruction, error) {
+	c, ok := Native.Command(j.Op)
+	if !ok {
+		return nil, fmt.Errorf("unknown op %q", j.Op)
+	}
+
+	numbered := c == CmdPush || c == CmdCopy || c == CmdSlide
+	labeled := c == CmdLabel || c == CmdCall || c == CmdJump || c == CmdJumpZero || c == CmdJumpMinus
+
+	switch {
+	case numbered && j.Number == nil:
+		return nil, fmt.Errorf("missing number for %s", j.Op)
+	case !numbered && j.Number != nil:
+		return nil, fmt.Errorf("unexpected number for %s", j.Op)
+	case labeled && j.Label == nil:
+		return nil, fmt.Errorf("missing label for %s", j.Op)
+	case !labeled && j.Label != nil:
+		return nil, fmt.Errorf("unexpected label for %s", j.Op)
+	}
+
+	var n int64
+	if numbered {
+		n = *j.Number
+	}
+
+	label := ""
+	if labeled {
+		if strings.Trim(*j.Label, "st") != "" {
+			return nil, fmt.Errorf("invalid label %q for %s", *j.Label, j.Op)
+		}
+		label = strings.TrimSuffix(EncodeLabel(*j.Label), "\n")
+	}
+
+	return newInstruction(c, n, label), nil
+}
+
+// MarshalInstruction returns the JSON for a single instruction.
+func MarshalInstruction(i Instruction) ([]byte, error) {
+	return json.Marshal(toJSON(i))
+}
+
+// UnmarshalInstruction returns the instruction for its JSON.
+func UnmarshalInstruction(data []byte) (Instruction, error) {
+	j := jsonInstruction{}
+	if err := json.Unmarshal(data, &j); err != nil {
+		return nil, err
+	}
+	return fromJSON(j)
+}
+
+// unmarshalAs unmarshals an instruction into c, which has to be the same
+// type.
+func unmarshalAs[T any, P interface {
+	*T
+	Instruction
+}](data []byte, c P) error {
+	i, err := UnmarshalInstruction(data)
+	if err != nil {
+		return err
+	}
+
+	v, ok := i.(P)
+	if !ok {
+		return fmt.Errorf("cannot unmarshal %s into %s", Native.Mnemonic(i.Type()), Native.Mnemonic(c.Type()))
+	}
+	*c = *v
+	return nil
+}
+
+func (c Push) MarshalJSON() ([]byte, error)        { return MarshalInstruction(c) }
+func (c Copy) MarshalJSON() ([]byte, error)        { return MarshalInstruction(c) }
+func (c Slide) MarshalJSON() ([]byte, error)       { return MarshalInstruction(c) }
+func (c Duplicate) MarshalJSON() ([]byte, error)   { return MarshalInstruction(c) }
+func (c Swap) MarshalJSON() ([]byte, error)        { return MarshalInstruction(c) }
+func (c Discard) MarshalJSON() ([]byte, error)     { return MarshalInstruction(c) }
+func (c Add) MarshalJSON() ([]byte, error)         { return MarshalInstruction(c) }
+func (c Subtract) MarshalJSON() ([]byte, error)    { return MarshalInstruction(c) }
+func (c Multiply) MarshalJSON() ([]byte, error)    { return MarshalInstruction(c) }
+func (c Divide) MarshalJSON() ([]byte, error)      { return MarshalInstruction(c) }
+func (c Modulo) MarshalJSON() ([]byte, error)      { return MarshalInstruction(c) }
+func (c Store) MarshalJSON() ([]byte, error)       { return MarshalInstruction(c) }
+func (c Load) MarshalJSON() ([]byte, error)        { return MarshalInstruction(c) }
+func (c Label) MarshalJSON() ([]byte, error)       { return MarshalInstruction(c) }
+func (c Call) MarshalJSON() ([]byte, error)        { return MarshalInstruction(c) }
+func (c Jump) MarshalJSON() ([]byte, error)        { return MarshalInstruction(c) }
+func (c JumpZero) MarshalJSON() ([]byte, error)    { return MarshalInstruction(c) }
+func (c JumpMinus) MarshalJSON() ([]byte, error)   { return MarshalInstruction(c) }
+func (c Return) MarshalJSON() ([]byte, error)      { return MarshalInstruction(c) }
+func (c Stop) MarshalJSON() ([]byte, error)        { return MarshalInstruction(c) }
+func (c PrintChar) MarshalJSON() ([]byte, error)   { return MarshalInstruction(c) }
+func (c PrintNumber) MarshalJSON() ([]byte, error) { return MarshalInstruction(c) }
+func (c ReadChar) MarshalJSON() ([]byte, error)    { return MarshalInstruction(c) }
+func (c ReadNumber) MarshalJSON() ([]byte, error)  { return MarshalInstruction(c) }
+
+func (c *Push) UnmarshalJSON(b []byte) error        { return unmarshalAs(b, c) }
+func (c *Copy) UnmarshalJSON(b []byte) error        { return unmarshalAs(b, c) }
+func (c *Slide) UnmarshalJSON(b []byte) error       { return unmarshalAs(b, c) }
+func (c *Duplicate) UnmarshalJSON(b []byte) error   { return unmarshalAs(b, c) }
+func (c *Swap) UnmarshalJSON(b []byte) error        { return unmarshalAs(b, c) }
+func (c *Discard) UnmarshalJSON(b []byte) error     { return unmarshalAs(b, c) }
+func (c *Add) UnmarshalJSON(b []byte) error         { return unmarshalAs(b, c) }
+func (c *Subtract) UnmarshalJSON(b []byte) error    { return unmarshalAs(b, c) }
+func (c *Multiply) UnmarshalJSON(b []byte) error    { return unmarshalAs(b, c) }
+func (c *Divide) UnmarshalJSON(b []byte) error      { return unmarshalAs(b, c) }
+func (c *Modulo) UnmarshalJSON(b []byte) error      { return unmarshalAs(b, c) }
+func (c *Store) UnmarshalJSON(b []byte) error       { return unmarshalAs(b, c) }
+func (c *Load) UnmarshalJSON(b []byte) error        { return unmarshalAs(b, c) }
+func (c *Label) UnmarshalJSON(b []byte) error       { return unmarshalAs(b, c) }
+func (c *Call) UnmarshalJSON(b []byte) error        { return unmarshalAs(b, c) }
+func (c *Jump) UnmarshalJSON(b []byte) error        { return unmarshalAs(b, c) }
+func (c *JumpZero) UnmarshalJSON(b []byte) error    { return unmarshalAs(b, c) }
+func (c *JumpMinus) UnmarshalJSON(b []byte) error   { return unmarshalAs(b, c) }
+func (c *Return) UnmarshalJSON(b []byte) error      { return unmarshalAs(b, c) }
+func (c *Stop) UnmarshalJSON(b []byte) error        { return unmarshalAs(b, c) }
+func (c *PrintChar) UnmarshalJSON(b []byte) error   { return unmarshalAs(b, c) }
+func (c *PrintNumber) UnmarshalJSON(b []byte) error { return unmarshalAs(b, c) }
+func (c *ReadChar) UnmarshalJSON(b []byte) error    { return unmarshalAs(b, c) }
+func (c *ReadNumber) UnmarshalJSON(b []byte) error  { return unmarshalAs(b, c) }
+
+// Program is a list of instructions and where each came from, for JSON.
+//
+//	{"version": 1, "instructions": [{"op": "push", "number": 1, "span": {...}}]}
+type Program struct {
+	Instructions []Instruction
+
+	// Either nil or one for each instruction.  Nil entries are left out.
+	Spans []*Span
+}
+
+// NewProgram returns a program with spans for the byte offsets of each
+// instruction.
+func NewProgram(prog []Instruction) *Program {
+	p := &Program{Instructions: prog, Spans: make([]*Span, len(prog))}
+	offset := 0
+	for idx, i := range prog {
+		end := offset + len(i.Wsp())
+		p.Spans[idx] = &Span{Start: offset, End: end}
+		offset = end
+	}
+	return p
+}
+
+type jsonProgram struct {
+	Version      int               `json:"version"`
+	Instructions []jsonInstruction `json:"instructions"`
+}
+
+func (p Program) MarshalJSON() ([]byte, error) {
+	jp := jsonProgram{Version: JSONVersion, Instructions: []jsonInstruction{}}
+	for idx, i := range p.Instructions {
+		j := toJSON(i)
+		if idx < len(p.Spans) {
+			j.Span = p.Spans[idx]
+		}
+		jp.Instructions = append(jp.Instructions, j)
+	}
+	return json.Marshal(jp)
+}
+
+func (p *Program) UnmarshalJSON(data []byte) error {
+	jp := jsonProgram{}
+	if err := json.Unmarshal(data, &jp); err != nil {
+		return err
+	}
+
+	if jp.Version != JSONVersion {
+		return fmt.Errorf("unsupported JSON program version %d", jp.Version)
+	}
+
+	p.Instructions = make([]Instruction, 0, len(jp.Instructions))
+	p.Spans = make([]*Span, 0, len(jp.Instructions))
+	for idx, j := range jp.Instructions {
+		i, err := fromJSON(j)
+		if err != nil {
+			return fmt.Errorf("instruction %d: %w", idx, err)
+		}
+		p.Instructions = append(p.Instructions, i)
+		p.Spans = append(p.Spans, j.Span)
+	}
+	return nil
+}
@@ -2,6 +2,7 @@ package instructions
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 )
 
@@ -29,3 +30,30 @@ func EncodeLabel(l string) string {
 func DecodeLabel(l string) string {
 	return strings.ReplaceAll(strings.ReplaceAll(l, "\t", "t"), " ", "s")
 }
+
+// NthLabel returns the nth label in order of length, then value, with
+// spaces sorting before tabs.  The empty label is never returned since not
+// all implementations accept it.
+func NthLabel(n int) string {
+	b := []byte(strconv.FormatInt(int64(n)+2, 2))[1:]
+	for i := range b {
+		if b[i] == '1' {
+			b[i] = '\t'
+		} else {
+			b[i] = ' '
+		}
+	}
+	return string(b)
+}
+
+// Visible returns whitespace with S for space, T for tab, and L for
+// newline.  Anything else is left alone.
+func Visible(ws string) string {
+	return strings.NewReplacer(" ", "S", "\t", "T", "\n", "L").Replace(ws)
+}
+
+// VisibleGlyphs is like Visible, but with · for space, → for tab, and ¶ for
+// newline.
+func VisibleGlyphs(ws string) string {
+	return strings.NewReplacer(" ", "·", "\t", "→", "\n", "¶").Replace(ws)
+}
@@ -0,0 +1,168 @@
+package whitespace
+
+import (
+	"encoding/json"
+	"testing"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// The JSON schema for instructions.  Changing any of these breaks files
+// written by earlier versions.
+func TestInstructionJSON(t *testing.T) {
+	tests := []struct {
+		inst inst.Instruction
+		json string
+	}{
+		{&inst.Push{Value: -3}, `{"op":"push","number":-3}`},
+		{&inst.Duplicate{}, `{"op":"duplicate"}`},
+		{&inst.Copy{Value: 2}, `{"op":"copy","number":2}`},
+		{&inst.Swap{}, `{"op":"swap"}`},
+		{&inst.Discard{}, `{"op":"discard"}`},
+		{&inst.Slide{Value: 0}, `{"op":"slide","number":0}`},
+		{&inst.Add{}, `{"op":"add"}`},
+		{&inst.Subtract{}, `{"op":"subtract"}`},
+		{&inst.Multiply{}, `{"op":"multiply"}`},
+		{&inst.Divide{}, `{"op":"divide"}`},
+		{&inst.Modulo{}, `{"op":"modulo"}`},
+		{&inst.Store{}, `{"op":"store"}`},
+		{&inst.Load{}, `{"op":"load"}`},
+		{&inst.Label{Value: " \t"}, `{"op":"label","label":"st"}`},
+		{&inst.Call{Value: "\t"}, `{"op":"call","label":"t"}`},
+		{&inst.Jump{Value: ""}, `{"op":"jump","label":""}`},
+		{&inst.JumpZero{Value: "  "}, `{"op":"jumpzero","label":"ss"}`},
+		{&inst.JumpMinus{Value: "\t\t"}, `{"op":"jumpminus","label":"tt"}`},
+		{&inst.Return{}, `{"op":"return"}`},
+		{&inst.Stop{}, `{"op":"stop"}`},
+		{&inst.PrintChar{}, `{"op":"printchar"}`},
+		{&inst.PrintNumber{}, `{"op":"printnumber"}`},
+		{&inst.ReadChar{}, `{"op":"readchar"}`},
+		{&inst.ReadNumber{}, `{"op":"readnumber"}`},
+	}
+
+	for _, tst := range tests {
+		data, err := json.Marshal(tst.inst)
+		if err != nil {
+			t.Logf("Marshal %T fail: %s", tst.inst, err)
+			t.Fail()
+			continue
+		}
+
+		if string(data) != tst.json {
+			t.Logf("Unexpected JSON for %T.\n Rec: %s\n Exp: %s", tst.inst, data, tst.json)
+			t.Fail()
+			continue
+		}
+
+		i, err := inst.UnmarshalInstruction(data)
+		if err != nil {
+			t.Logf("UnmarshalInstruction %s fail: %s", data, err)
+			t.Fail()
+			continue
+		}
+
+		if i.Wsp() != tst.inst.Wsp() {
+			t.Logf("Unexpected round trip for %s.\n Rec: %q\n Exp: %q", data, i.Wsp(), tst.inst.Wsp())
+			t.Fail()
+		}
+	}
+
+	push := inst.Push{}
+	if err := json.Unmarshal([]byte(`{"op":"push","number":7}`), &push); err != nil || push.Value != 7 {
+		t.Logf("Unmarshal into Push fail: %v %v", push, err)
+		t.Fail()
+	}
+
+	if err := json.Unmarshal([]byte(`{"op":"pop"}`), &push); err == nil || err.Error() != `unknown op "pop"` {
+		t.Logf("Unexpected error: %v", err)
+		t.Fail()
+	}
+
+	if err := json.Unmarshal([]byte(`{"op":"add"}`), &push); err == nil || err.Error() != "cannot unmarshal add into push" {
+		t.Logf("Unexpected error: %v", err)
+		t.Fail()
+	}
+
+	bad := []struct {
+		json  string
+		error string
+	}{
+		{`{"op":"push"}`, "missing number for push"},
+		{`{"op":"add","number":1}`, "unexpected number for add"},
+		{`{"op":"call"}`, "missing label for call"},
+		{`{"op":"stop","label":"s"}`, "unexpected label for stop"},
+		{`{"op":"jump","label":"sx"}`, `invalid label "sx" for jump`},
+		{`{"op":"dup"}`, `unknown op "dup"`},
+	}
+
+	for _, tst := range bad {
+		_, err := inst.UnmarshalInstruction([]byte(tst.json))
+		if err == nil || err.Error() != tst.error {
+			t.Logf("Unexpected error for %s.\n Rec: %v\n Exp: %s", tst.json, err, tst.error)
+			t.Fail()
+		}
+	}
+}
+
+func TestProgramJSON(t *testing.T) {
+	prog := inst.NewProgram([]inst.Instruction{
+		&inst.Push{Value: 1},
+		&inst.PrintNumber{},
+		&inst.Stop{},
+	})
+	prog.Spans[0].File = "one.wsa"
+	prog.Spans[0].Line = 1
+	prog.Spans[0].Column = 1
+
+	expected := `{"version":1,"instructions":[` +
+		`{"op":"push","number":1,"span":{"file":"one.wsa","line":1,"column":1,"start":0,"end":5}},` +
+		`{"op":"printnumber","span":{"start":5,"end":9}},` +
+		`{"op":"stop","span":{"start":9,"end":12}}]}`
+
+	data, err := json.Marshal(prog)
+	if err != nil {
+		t.Fatalf("Marshal fail: %s", err)
+	}
+
+	if string(data) != expected {
+		t.Fatalf("Unexpected JSON.\n Rec: %s\n Exp: %s", data, expected)
+	}
+
+	decoded := &inst.Program{}
+	if err := json.Unmarshal(data, decoded); err != nil {
+		t.Fatalf("Unmarshal fail: %s", err)
+	}
+
+	if len(decoded.Instructions) != 3 || decoded.Instructions[0].Wsp() != "   \t\n" {
+		t.Fatalf("Unexpected instructions: %v", decoded.Instructions)
+	}
+
+	if *decoded.Spans[0] != *prog.Spans[0] || *decoded.Spans[2] != *prog.Spans[2] {
+		t.Logf("Unexpected spans: %v %v", decoded.Spans[0], decoded.Spans[2])
+		t.Fail()
+	}
+
+	// spans are optional
+	data = []byte(`{"version":1,"instructions":[{"op":"stop"}]}`)
+	if err := json.Unmarshal(data, decoded); err != nil || len(decoded.Instructions) != 1 || decoded.Spans[0] != nil {
+		t.Logf("Unmarshal without spans fail: %v", err)
+		t.Fail()
+	}
+
+	bad := []struct {
+		json  string
+		error string
+	}{
+		{`{"version":2,"instructions":[]}`, "unsupported JSON program version 2"},
+		{`{"instructions":[]}`, "unsupported JSON program version 0"},
+		{`{"version":1,"instructions":[{"op":"add"},{"op":"push"}]}`, "instruction 1: missing number for push"},
+	}
+
+	for _, tst := range bad {
+		err := json.Unmarshal([]byte(tst.json), &inst.Program{})
+		if err == nil || err.Error() != tst.error {
+			t.Logf("Unexpected error for %s.\n Rec: %v\n Exp: %s", tst.json, err, tst.error)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,140 @@
+// Package lint finds instructions in whitespace programs that are likely to
+// be mistakes.
+package lint
+
+import (
+	"fmt"
+	"sort"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// Problem is something a rule found.
+type Problem struct {
+	Rule    string
+	Index   int // instruction the problem is at
+	Message string
+}
+
+// Rule looks for one kind of problem.
+type Rule struct {
+	Name        string
+	Description string
+	Default     bool // run unless it's disabled
+	check       func(p *program) []Problem
+}
+
+// Rules are every rule.
+var Rules = []Rule{
+	{"unused-label", "Labels that nothing calls or jumps to", true, unusedLabels},
+	{"unreachable", "Instructions that can't be reached from the start of the program", true, unreachable},
+	{"label-prefix", "Labels whose encoding is the start of another label's encoding", true, labelPrefixes},
+	{"call-return", "Calls to subroutines that can't reach a return", true, callsWithoutReturn},
+	{"jump-next", "Jumps and conditional jumps to the instruction right after them", true, jumpsToNext},
+	{"slide-copy-zero", "slide 0, which does nothing, and copy 0, which is duplicate", true, slideCopyZero},
+	{"missing-stop", "Programs that can run off the end without a stop", true, missingStop},
+	{"dead-push", "Values that are discarded right after they are pushed", true, deadPushes},
+}
+
+// Select returns the rules that are on by default along with the rules
+// named in enable, without the rules named in disable.
+func Select(enable, disable []string) ([]Rule, error) {
+	known := make(map[string]bool)
+	for _, r := range Rules {
+		known[r.Name] = true
+	}
+
+	for _, name := range append(append([]string{}, enable...), disable...) {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown lint rule %q", name)
+		}
+	}
+
+	rules := []Rule{}
+	for _, r := range Rules {
+		if (r.Default || contains(enable, r.Name)) && !contains(disable, r.Name) {
+			rules = append(rules, r)
+		}
+	}
+	return rules, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+	return false
+}
+
+// Check runs the rules over prog and returns their problems in program
+// order.  names has label names by their encodings and can be nil.  Labels
+// with a name are taken to have been given their encodings by the
+// assembler.
+func Check(prog []inst.Instruction, names map[string]string, rules []Rule) []Problem {
+	p := newProgram(prog, names)
+
+	problems := []Problem{}
+	for _, r := range rules {
+		for _, prob := range r.check(p) {
+			prob.Rule = r.Name
+			problems = append(problems, prob)
+		}
+	}
+
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].Index < problems[j].Index
+	})
+	return problems
+}
+
+// program is what the rules look at.
+type program struct {
+	prog   []inst.Instruction
+	names  map[string]string
+	labels map[string]int  // index of each label's definition
+	used   map[string]bool // labels that are called or jumped to
+	live   []bool          // reachable instructions
+}
+
+func newProgram(prog []inst.Instruction, names map[string]string) *program {
+	p := &program{
+		prog:   prog,
+		names:  names,
+		labels: make(map[string]int),
+		used:   make(map[string]bool),
+	}
+
+	for idx, i := range prog {
+		switch {
+		case i.Type() == inst.CmdLabel:
+			if _, ok := p.labels[label(i)]; !ok {
+				p.labels[label(i)] = idx
+			}
+		case inst.IsBranch(i):
+			p.used[label(i)] = true
+		}
+	}
+
+	p.live = inst.Reachable(prog, 0, true)
+	return p
+}
+
+// name returns the name of a label for messages.
+func (p *program) name(l string) string {
+	if name, ok := p.names[l]; ok {
+		return name
+	}
+	return inst.DecodeLabel(l)
+}
+
+// named returns true if the label has a name.
+func (p *program) named(l string) bool {
+	_, ok := p.names[l]
+	return ok
+}
+
+func label(i inst.Instruction) string {
+	return i.(inst.FlowControl).Label()
+}
@@ -0,0 +1,186 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/zorchenhimer/whitespace/asm"
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+func TestCheck(t *testing.T) {
+	prog := []inst.Instruction{
+		&inst.Call{Value: " "},     // 0
+		&inst.Push{Value: 1},       // 1
+		&inst.Discard{},            // 2
+		&inst.Jump{Value: "\t"},    // 3
+		&inst.Label{Value: "  "},   // 4
+		&inst.Label{Value: "\t"},   // 5
+		&inst.Slide{Value: 0},      // 6
+		&inst.Copy{Value: 0},       // 7
+		&inst.Call{Value: "\t\t"},  // 8
+		&inst.Stop{},               // 9
+		&inst.Push{Value: 2},       // 10
+		&inst.Label{Value: " "},    // 11
+		&inst.Return{},             // 12
+		&inst.Label{Value: "\t\t"}, // 13
+		&inst.PrintNumber{},        // 14
+	}
+	names := map[string]string{" ": "sub"}
+
+	expected := []string{
+		`1 dead-push: push 1 is discarded right away`,
+		`3 jump-next: jump to "t" goes to the next instruction`,
+		`4 unused-label: label "ss" is never used`,
+		`4 unreachable: unreachable code`,
+		`5 label-prefix: label "t" is the start of label "tt"`,
+		`6 slide-copy-zero: slide 0 does nothing`,
+		`7 slide-copy-zero: copy 0 is the same as duplicate`,
+		`8 call-return: subroutine "tt" never returns`,
+		`10 unreachable: unreachable code`,
+		`11 label-prefix: label "sub" is the start of label "ss"`,
+		`14 missing-stop: the program can run past its end without a stop`,
+	}
+
+	problems := Check(prog, names, Rules)
+	if len(problems) != len(expected) {
+		t.Fatalf("Unexpected problems: %v", problems)
+	}
+
+	for i, p := range problems {
+		rec := fmt.Sprintf("%d %s: %s", p.Index, p.Rule, p.Message)
+		if rec != expected[i] {
+			t.Logf("Unexpected problem %d.\n Rec: %s\n Exp: %s", i, rec, expected[i])
+			t.Fail()
+		}
+	}
+
+	rules, err := Select(nil, []string{"unreachable", "label-prefix", "unused-label"})
+	if err != nil {
+		t.Fatalf("Select() error: %s", err)
+	}
+	if problems := Check(prog, names, rules); len(problems) != 6 {
+		t.Fatalf("Unexpected problems without three rules: %v", problems)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	rules, err := Select(nil, nil)
+	if err != nil || len(rules) != len(Rules) {
+		t.Fatalf("Unexpected default rules: %v %v", names(rules), err)
+	}
+
+	rules, err = Select([]string{"label-prefix"}, []string{"dead-push", "unreachable"})
+	if err != nil || len(rules) != len(Rules)-2 || !contains(names(rules), "label-prefix") || contains(names(rules), "dead-push") {
+		t.Fatalf("Unexpected rules: %v %v", names(rules), err)
+	}
+
+	_, err = Select(nil, []string{"nope"})
+	if err == nil || err.Error() != `unknown lint rule "nope"` {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// a program that does everything right
+	prog := []inst.Instruction{
+		&inst.Call{Value: " "},
+		&inst.Stop{},
+		&inst.Label{Value: " "},
+		&inst.Push{Value: 1},
+		&inst.PrintNumber{},
+		&inst.Return{},
+	}
+	if problems := Check(prog, nil, Rules); len(problems) != 0 {
+		t.Fatalf("Unexpected problems: %v", problems)
+	}
+}
+
+func TestJumpNext(t *testing.T) {
+	prog := []inst.Instruction{
+		&inst.JumpZero{Value: " "},   // 0
+		&inst.Label{Value: " "},      // 1
+		&inst.JumpMinus{Value: "\t"}, // 2
+		&inst.Label{Value: "  "},     // 3
+		&inst.Label{Value: "\t"},     // 4
+		&inst.Stop{},                 // 5
+	}
+
+	expected := []string{
+		`0 jump-next: jumpzero to "s" goes to the next instruction`,
+		`2 jump-next: jumpminus to "t" goes to the next instruction`,
+	}
+
+	rules, err := Select(nil, []string{"unused-label", "label-prefix"})
+	if err != nil {
+		t.Fatalf("Select() error: %s", err)
+	}
+
+	problems := Check(prog, nil, rules)
+	if len(problems) != len(expected) {
+		t.Fatalf("Unexpected problems: %v", problems)
+	}
+
+	for i, p := range problems {
+		rec := fmt.Sprintf("%d %s: %s", p.Index, p.Rule, p.Message)
+		if rec != expected[i] {
+			t.Logf("Unexpected problem %d.\n Rec: %s\n Exp: %s", i, rec, expected[i])
+			t.Fail()
+		}
+	}
+}
+
+func names(rules []Rule) []string {
+	lst := []string{}
+	for _, r := range rules {
+		lst = append(lst, r.Name)
+	}
+	return lst
+}
+
+func TestAssembled(t *testing.T) {
+	// the assembler's encodings start with each other, which is fine
+	src := `
+	call print
+	call read
+	stop
+print:
+.loop:
+	duplicate
+	jumpzero 1f
+	printchar
+	jump .loop
+1:
+2:
+	discard
+	return
+read:
+	readchar
+	return
+`
+	prog, err := asm.Assemble(strings.NewReader(src))
+	if err != nil {
+		t.Fatalf("Assemble() error: %s", err)
+	}
+
+	rules, err := Select(nil, nil)
+	if err != nil {
+		t.Fatalf("Select() error: %s", err)
+	}
+
+	// numeric labels are named as they are written
+	problems := Check(prog.Instructions, prog.LabelNames(), rules)
+	if len(problems) != 1 || problems[0].Message != `label "2" is never used` {
+		t.Fatalf("Unexpected problems: %v", problems)
+	}
+
+	// without the names nothing says the assembler picked the encodings
+	prefixes := 0
+	for _, p := range Check(prog.Instructions, nil, rules) {
+		if p.Rule == "label-prefix" {
+			prefixes++
+		}
+	}
+	if prefixes == 0 {
+		t.Fatalf("Expected label-prefix problems without names")
+	}
+}
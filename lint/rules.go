@@ -0,0 +1,163 @@
+package lint
+
+import (
+	"fmt"
+	"strings"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+func unusedLabels(p *program) []Problem {
+	problems := []Problem{}
+	for idx, i := range p.prog {
+		if i.Type() == inst.CmdLabel && !p.used[label(i)] {
+			problems = append(problems, Problem{
+				Index:   idx,
+				Message: fmt.Sprintf("label %q is never used", p.name(label(i))),
+			})
+		}
+	}
+	return problems
+}
+
+// unreachable reports the start of each run of unreachable instructions.
+func unreachable(p *program) []Problem {
+	problems := []Problem{}
+	for idx := range p.prog {
+		if !p.live[idx] && (idx == 0 || p.live[idx-1]) {
+			problems = append(problems, Problem{Index: idx, Message: "unreachable code"})
+		}
+	}
+	return problems
+}
+
+// labelPrefixes reports each label that is the start of another label, which
+// is easy to mix up with it when reading the whitespace.  Pairs of labels
+// with names are left out, since the assembler picked their encodings.
+func labelPrefixes(p *program) []Problem {
+	defined := []string{}
+	for _, i := range p.prog {
+		if i.Type() == inst.CmdLabel {
+			defined = append(defined, label(i))
+		}
+	}
+
+	problems := []Problem{}
+	for _, a := range defined {
+		for _, b := range defined {
+			if a != b && strings.HasPrefix(b, a) && !(p.named(a) && p.named(b)) {
+				problems = append(problems, Problem{
+					Index:   p.labels[a],
+					Message: fmt.Sprintf("label %q is the start of label %q", p.name(a), p.name(b)),
+				})
+				break
+			}
+		}
+	}
+	return problems
+}
+
+// callsWithoutReturn reports the first call to each subroutine that can't
+// reach a return.  Calls inside the subroutine are assumed to return.
+func callsWithoutReturn(p *program) []Problem {
+	problems := []Problem{}
+	done := make(map[string]bool)
+
+	for idx, i := range p.prog {
+		if i.Type() != inst.CmdCall || done[label(i)] {
+			continue
+		}
+		done[label(i)] = true
+
+		start, ok := p.labels[label(i)]
+		if !ok {
+			continue
+		}
+
+		returns := false
+		for sub, seen := range inst.Reachable(p.prog, start, false) {
+			if seen && p.prog[sub].Type() == inst.CmdReturn {
+				returns = true
+				break
+			}
+		}
+
+		if !returns {
+			problems = append(problems, Problem{
+				Index:   idx,
+				Message: fmt.Sprintf("subroutine %q never returns", p.name(label(i))),
+			})
+		}
+	}
+	return problems
+}
+
+// jumpsToNext reports jumps to a label that is defined right after them.
+// A conditional jump there only pops its value, like a discard.
+func jumpsToNext(p *program) []Problem {
+	problems := []Problem{}
+	for idx, i := range p.prog {
+		switch i.Type() {
+		case inst.CmdJump, inst.CmdJumpZero, inst.CmdJumpMinus:
+		default:
+			continue
+		}
+
+		for next := idx + 1; next < len(p.prog) && p.prog[next].Type() == inst.CmdLabel; next++ {
+			if label(p.prog[next]) == label(i) {
+				problems = append(problems, Problem{
+					Index:   idx,
+					Message: fmt.Sprintf("%s to %q goes to the next instruction", inst.Native.Mnemonic(i.Type()), p.name(label(i))),
+				})
+				break
+			}
+		}
+	}
+	return problems
+}
+
+func slideCopyZero(p *program) []Problem {
+	problems := []Problem{}
+	for idx, i := range p.prog {
+		switch i := i.(type) {
+		case *inst.Slide:
+			if i.Value == 0 {
+				problems = append(problems, Problem{Index: idx, Message: "slide 0 does nothing"})
+			}
+		case *inst.Copy:
+			if i.Value == 0 {
+				problems = append(problems, Problem{Index: idx, Message: "copy 0 is the same as duplicate"})
+			}
+		}
+	}
+	return problems
+}
+
+// missingStop reports the last instruction if the program can run past it.
+func missingStop(p *program) []Problem {
+	last := len(p.prog) - 1
+	if last < 0 || !p.live[last] || !inst.FallsThrough(p.prog[last]) {
+		return nil
+	}
+	return []Problem{{Index: last, Message: "the program can run past its end without a stop"}}
+}
+
+// deadPushes reports values that are put on the stack only to be discarded
+// by the next instruction, which leaves the stack as it was.
+func deadPushes(p *program) []Problem {
+	problems := []Problem{}
+	for idx := 0; idx+1 < len(p.prog); idx++ {
+		if p.prog[idx+1].Type() != inst.CmdDiscard {
+			continue
+		}
+
+		switch p.prog[idx].Type() {
+		case inst.CmdPush, inst.CmdDuplicate, inst.CmdCopy:
+			problems = append(problems, Problem{
+				Index:   idx,
+				Message: fmt.Sprintf("%s is discarded right away", p.prog[idx].Asm()),
+			})
+		}
+	}
+	return problems
+}
@@ -0,0 +1,81 @@
+package optimize
+
+import (
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// removeDead drops unreachable code that starts with a label, up to the next
+// label or reachable instruction, as long as nothing that is kept references
+// that label.  Returns every removed label.
+func removeDead(prog []inst.Instruction) ([]inst.Instruction, []string) {
+	live := inst.Reachable(prog, 0, true)
+
+	// run index for each instruction, or -1 if it's kept
+	runs := make([]int, len(prog))
+	starts := []int{}
+	for idx := range prog {
+		runs[idx] = -1
+		if live[idx] {
+			continue
+		}
+
+		if prog[idx].Type() == inst.CmdLabel {
+			runs[idx] = len(starts)
+			starts = append(starts, idx)
+		} else if idx > 0 {
+			runs[idx] = runs[idx-1]
+		}
+	}
+
+	if len(starts) == 0 {
+		return prog, nil
+	}
+
+	removed := make([]bool, len(starts))
+	for i := range removed {
+		removed[i] = true
+	}
+
+	// Keep any run with a label that is referenced from kept code.  Keeping
+	// a run can expose more references, so repeat until nothing changes.
+	for changed := true; changed; {
+		changed = false
+
+		defined := make(map[string]int)
+		for idx, i := range prog {
+			if runs[idx] != -1 && removed[runs[idx]] && i.Type() == inst.CmdLabel {
+				defined[i.(inst.FlowControl).Label()] = runs[idx]
+			}
+		}
+
+		for idx, i := range prog {
+			if runs[idx] != -1 && removed[runs[idx]] {
+				continue
+			}
+			if !inst.IsBranch(i) {
+				continue
+			}
+
+			if r, ok := defined[i.(inst.FlowControl).Label()]; ok && removed[r] {
+				removed[r] = false
+				changed = true
+			}
+		}
+	}
+
+	out := []inst.Instruction{}
+	for idx, i := range prog {
+		if runs[idx] == -1 || !removed[runs[idx]] {
+			out = append(out, i)
+		}
+	}
+
+	labels := []string{}
+	for r, start := range starts {
+		if removed[r] {
+			labels = append(labels, prog[start].(inst.FlowControl).Label())
+		}
+	}
+
+	return out, labels
+}
@@ -0,0 +1,181 @@
+package optimize
+
+import (
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// subroutine is the code from a called label up to the first return after
+// it.
+type subroutine struct {
+	label string
+	start int // index of the label
+	end   int // index of the return
+}
+
+func (s subroutine) body(prog []inst.Instruction) []inst.Instruction {
+	return prog[s.start+1 : s.end]
+}
+
+// findSubroutines returns every call target that is followed by a return.
+func findSubroutines(prog []inst.Instruction) map[string]subroutine {
+	labels := labelIndexes(prog)
+	subs := make(map[string]subroutine)
+
+	for _, i := range prog {
+		if i.Type() != inst.CmdCall {
+			continue
+		}
+
+		lbl := i.(inst.FlowControl).Label()
+		if _, done := subs[lbl]; done {
+			continue
+		}
+
+		start, ok := labels[lbl]
+		if !ok {
+			continue
+		}
+
+		for end := start + 1; end < len(prog); end++ {
+			if prog[end].Type() == inst.CmdReturn {
+				subs[lbl] = subroutine{label: lbl, start: start, end: end}
+				break
+			}
+		}
+	}
+
+	return subs
+}
+
+// recursive returns true if the subroutine can end up calling itself.
+func recursive(prog []inst.Instruction, subs map[string]subroutine, label string) bool {
+	seen := make(map[string]bool)
+	queue := []string{label}
+
+	for len(queue) > 0 {
+		sub, ok := subs[queue[0]]
+		queue = queue[1:]
+		if !ok {
+			continue
+		}
+
+		for _, i := range sub.body(prog) {
+			if i.Type() != inst.CmdCall {
+				continue
+			}
+
+			target := i.(inst.FlowControl).Label()
+			if target == label {
+				return true
+			}
+
+			if !seen[target] {
+				seen[target] = true
+				queue = append(queue, target)
+			}
+		}
+	}
+
+	return false
+}
+
+// inlinable returns true if a copy of the subroutine body can replace a
+// call to it.  The body must be small, must not recurse, and may only jump
+// to labels defined inside of itself.  It can't call a label defined inside
+// of itself either, since that call needs the return that isn't copied.
+func inlinable(prog []inst.Instruction, subs map[string]subroutine, sub subroutine, size int) bool {
+	body := sub.body(prog)
+	if len(body) > size {
+		return false
+	}
+
+	internal := make(map[string]bool)
+	for _, i := range body {
+		if i.Type() == inst.CmdLabel {
+			internal[i.(inst.FlowControl).Label()] = true
+		}
+	}
+
+	for _, i := range body {
+		switch i.Type() {
+		case inst.CmdJump, inst.CmdJumpZero, inst.CmdJumpMinus:
+			if !internal[i.(inst.FlowControl).Label()] {
+				return false
+			}
+		case inst.CmdCall:
+			if internal[i.(inst.FlowControl).Label()] {
+				return false
+			}
+		}
+	}
+
+	return !recursive(prog, subs, sub.label)
+}
+
+// inlineCalls replaces calls to inlinable subroutines with a copy of their
+// body.  Labels inside the body are renamed in each copy.  The subroutines
+// themselves are left in place.
+func inlineCalls(prog []inst.Instruction, size int) ([]inst.Instruction, int) {
+	subs := findSubroutines(prog)
+	candidates := make(map[string]bool)
+	for lbl, sub := range subs {
+		if inlinable(prog, subs, sub, size) {
+			candidates[lbl] = true
+		}
+	}
+
+	if len(candidates) == 0 {
+		return prog, 0
+	}
+
+	alloc := newLabelAllocator(prog)
+	out := []inst.Instruction{}
+	count := 0
+
+	for _, i := range prog {
+		if i.Type() != inst.CmdCall || !candidates[i.(inst.FlowControl).Label()] {
+			out = append(out, i)
+			continue
+		}
+
+		body := subs[i.(inst.FlowControl).Label()].body(prog)
+		renamed := make(map[string]string)
+		for _, b := range body {
+			if b.Type() == inst.CmdLabel {
+				renamed[b.(inst.FlowControl).Label()] = alloc.New()
+			}
+		}
+
+		for _, b := range body {
+			if fc, ok := b.(inst.FlowControl); ok {
+				if lbl, ok := renamed[fc.Label()]; ok {
+					b = relabel(b, lbl)
+				}
+			}
+			out = append(out, b)
+		}
+		count++
+	}
+
+	return out, count
+}
+
+// tailCalls replaces a call that is immediately followed by a return with a
+// jump.  The callee's return will then go straight back to our caller.
+func tailCalls(prog []inst.Instruction) ([]inst.Instruction, int) {
+	out := []inst.Instruction{}
+	count := 0
+
+	for idx := 0; idx < len(prog); idx++ {
+		i := prog[idx]
+		if i.Type() == inst.CmdCall && idx+1 < len(prog) && prog[idx+1].Type() == inst.CmdReturn {
+			out = append(out, &inst.Jump{Value: i.(inst.FlowControl).Label()})
+			count++
+			idx++
+			continue
+		}
+		out = append(out, i)
+	}
+
+	return out, count
+}
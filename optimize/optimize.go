@@ -0,0 +1,189 @@
+// Package optimize implements whole program transformations on parsed
+// whitespace instruction lists.
+package optimize
+
+import (
+	"fmt"
+	"strings"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+// Subroutines with a body longer than this are not inlined unless
+// Options.InlineSize says otherwise.
+const DefaultInlineSize = 8
+
+// Inlining is repeated to pick up calls exposed by a previous pass, but not
+// forever.
+const maxInlinePasses = 4
+
+type Options struct {
+	// Maximum number of instructions in a subroutine body, not counting
+	// its label and return, for it to be inlined.  Zero uses
+	// DefaultInlineSize and a negative value disables inlining.
+	InlineSize int
+}
+
+type Report struct {
+	InstructionsBefore int
+	InstructionsAfter  int
+	BytesBefore        int
+	BytesAfter         int
+
+	// Call sites replaced with a copy of the subroutine body.
+	Inlined int
+
+	// "call X; return" pairs replaced with "jump X".
+	TailCalls int
+
+	// Labels at the start of each run of code removed because nothing
+	// could reach it.
+	Removed []string
+
+	// A static estimate of the executed instructions saved, counting each
+	// optimized site as running once.  An inlined call saves the call and
+	// the return, a tail call saves the return.  The real savings depend
+	// on how often each site runs and aren't measured.
+	EstimatedStepsSaved int
+}
+
+func (r Report) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "instructions: %d -> %d\n", r.InstructionsBefore, r.InstructionsAfter)
+	fmt.Fprintf(sb, "bytes: %d -> %d\n", r.BytesBefore, r.BytesAfter)
+	fmt.Fprintf(sb, "inlined calls: %d\n", r.Inlined)
+	fmt.Fprintf(sb, "tail calls: %d\n", r.TailCalls)
+	fmt.Fprintf(sb, "removed labels: %d\n", len(r.Removed))
+	for _, lbl := range r.Removed {
+		fmt.Fprintf(sb, "  %s\n", inst.DecodeLabel(lbl))
+	}
+	fmt.Fprintf(sb, "estimated instructions saved with each site run once: %d", r.EstimatedStepsSaved)
+	return sb.String()
+}
+
+// Program inlines small non-recursive subroutines, turns tail calls into
+// jumps, and removes subroutines that can't be reached from the first
+// instruction.  The input slice is not modified.
+func Program(prog []inst.Instruction, opts Options) ([]inst.Instruction, *Report, error) {
+	if opts.InlineSize == 0 {
+		opts.InlineSize = DefaultInlineSize
+	}
+
+	if err := checkLabels(prog); err != nil {
+		return nil, nil, err
+	}
+
+	report := &Report{
+		InstructionsBefore: len(prog),
+		BytesBefore:        Size(prog),
+	}
+
+	out := make([]inst.Instruction, len(prog))
+	copy(out, prog)
+
+	if opts.InlineSize > 0 {
+		for i := 0; i < maxInlinePasses; i++ {
+			var n int
+			out, n = inlineCalls(out, opts.InlineSize)
+			if n == 0 {
+				break
+			}
+			report.Inlined += n
+		}
+	}
+
+	out, report.TailCalls = tailCalls(out)
+	out, report.Removed = removeDead(out)
+
+	report.EstimatedStepsSaved = report.Inlined*2 + report.TailCalls
+	report.InstructionsAfter = len(out)
+	report.BytesAfter = Size(out)
+	return out, report, nil
+}
+
+// Size returns the length of the program in bytes when encoded as
+// whitespace.
+func Size(prog []inst.Instruction) int {
+	size := 0
+	for _, i := range prog {
+		size += len(i.Wsp())
+	}
+	return size
+}
+
+func checkLabels(prog []inst.Instruction) error {
+	seen := make(map[string]bool)
+	for _, i := range prog {
+		if i.Type() != inst.CmdLabel {
+			continue
+		}
+
+		lbl := i.(inst.FlowControl).Label()
+		if seen[lbl] {
+			return fmt.Errorf("Duplicate label %q", inst.DecodeLabel(lbl))
+		}
+		seen[lbl] = true
+	}
+	return nil
+}
+
+// labelIndexes maps label values to the index of their definition.
+func labelIndexes(prog []inst.Instruction) map[string]int {
+	labels := make(map[string]int)
+	for idx, i := range prog {
+		if i.Type() == inst.CmdLabel {
+			labels[i.(inst.FlowControl).Label()] = idx
+		}
+	}
+	return labels
+}
+
+// usedLabels returns every label that is defined or referenced.
+func usedLabels(prog []inst.Instruction) map[string]bool {
+	used := make(map[string]bool)
+	for _, i := range prog {
+		if fc, ok := i.(inst.FlowControl); ok {
+			used[fc.Label()] = true
+		}
+	}
+	return used
+}
+
+// relabel returns a copy of the flow control instruction i pointing at
+// label instead.
+func relabel(i inst.Instruction, label string) inst.Instruction {
+	switch i.Type() {
+	case inst.CmdLabel:
+		return &inst.Label{Value: label}
+	case inst.CmdCall:
+		return &inst.Call{Value: label}
+	case inst.CmdJump:
+		return &inst.Jump{Value: label}
+	case inst.CmdJumpZero:
+		return &inst.JumpZero{Value: label}
+	case inst.CmdJumpMinus:
+		return &inst.JumpMinus{Value: label}
+	}
+	return i
+}
+
+// labelAllocator hands out labels that aren't used anywhere in a program.
+type labelAllocator struct {
+	used map[string]bool
+	next int
+}
+
+func newLabelAllocator(prog []inst.Instruction) *labelAllocator {
+	return &labelAllocator{used: usedLabels(prog)}
+}
+
+func (a *labelAllocator) New() string {
+	for {
+		lbl := inst.NthLabel(a.next)
+		a.next++
+		if !a.used[lbl] {
+			a.used[lbl] = true
+			return lbl
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package optimize
+
+import (
+	"strings"
+	"testing"
+
+	ws "github.com/zorchenhimer/whitespace"
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+func run(t *testing.T, prog []inst.Instruction) string {
+	t.Helper()
+
+	sb := &strings.Builder{}
+	for _, i := range prog {
+		sb.WriteString(i.Wsp())
+	}
+
+	e, err := ws.NewEngine(strings.NewReader(sb.String()))
+	if err != nil {
+		t.Fatalf("Engine creation fail: %s", err)
+	}
+
+	out := &strings.Builder{}
+	err = e.Run(nil, out)
+	if err != nil {
+		t.Fatalf("Run fail: %s", err)
+	}
+	return out.String()
+}
+
+func TestProgram(t *testing.T) {
+	tests := []struct {
+		Name      string
+		Input     []inst.Instruction
+		Options   Options
+		Inlined   int
+		TailCalls int
+		Removed   []string
+	}{
+		{"Inline", []inst.Instruction{
+			&inst.Push{Value: 3},
+			&inst.Call{Value: "\t"},
+			&inst.Call{Value: "\t"},
+			&inst.PrintNumber{},
+			&inst.Stop{},
+			&inst.Label{Value: "\t"},
+			&inst.Push{Value: 2},
+			&inst.Multiply{},
+			&inst.Return{},
+		}, Options{}, 2, 0, []string{"\t"}},
+
+		{"Internal labels", []inst.Instruction{
+			&inst.Push{Value: 0},
+			&inst.Call{Value: "\t"},
+			&inst.PrintNumber{},
+			&inst.Push{Value: 5},
+			&inst.Call{Value: "\t"},
+			&inst.PrintNumber{},
+			&inst.Stop{},
+			&inst.Label{Value: "\t"},
+			&inst.Duplicate{},
+			&inst.JumpZero{Value: "\t\t"},
+			&inst.Push{Value: 10},
+			&inst.Add{},
+			&inst.Label{Value: "\t\t"},
+			&inst.Return{},
+		}, Options{}, 2, 0, []string{"\t", "\t\t"}},
+
+		{"Internal call", []inst.Instruction{
+			&inst.Push{Value: 5},
+			&inst.Call{Value: "\t"},
+			&inst.PrintNumber{},
+			&inst.Stop{},
+			&inst.Label{Value: "\t"},
+			&inst.Call{Value: "\t\t"},
+			&inst.Label{Value: "\t\t"},
+			&inst.Push{Value: 1},
+			&inst.Add{},
+			&inst.Return{},
+		}, Options{}, 2, 0, []string{"\t", "\t\t"}},
+
+		{"Recursive", []inst.Instruction{
+			&inst.Push{Value: 3},
+			&inst.Call{Value: "\t"},
+			&inst.Stop{},
+			&inst.Label{Value: "\t"},
+			&inst.Duplicate{},
+			&inst.PrintNumber{},
+			&inst.Push{Value: 1},
+			&inst.Subtract{},
+			&inst.Duplicate{},
+			&inst.JumpZero{Value: "\t\t"},
+			&inst.Call{Value: "\t"},
+			&inst.Label{Value: "\t\t"},
+			&inst.Return{},
+		}, Options{}, 0, 0, nil},
+
+		{"Tail call", []inst.Instruction{
+			&inst.Push{Value: 1},
+			&inst.Call{Value: "\t"},
+			&inst.Stop{},
+			&inst.Label{Value: "\t"},
+			&inst.Push{Value: 1},
+			&inst.Add{},
+			&inst.Call{Value: "\t\t"},
+			&inst.Return{},
+			&inst.Label{Value: "\t\t"},
+			&inst.PrintNumber{},
+			&inst.Return{},
+		}, Options{InlineSize: -1}, 0, 1, nil},
+
+		{"Dead subroutine", []inst.Instruction{
+			&inst.Push{Value: 7},
+			&inst.PrintNumber{},
+			&inst.Stop{},
+			&inst.Label{Value: " "},
+			&inst.Call{Value: "\t"},
+			&inst.Return{},
+			&inst.Label{Value: "\t"},
+			&inst.Return{},
+		}, Options{InlineSize: -1}, 0, 1, []string{" ", "\t"}},
+	}
+
+	for _, tst := range tests {
+		t.Log(tst.Name)
+		expected := run(t, tst.Input)
+
+		out, report, err := Program(tst.Input, tst.Options)
+		if err != nil {
+			t.Logf("Program() error: %s", err)
+			t.Fail()
+			continue
+		}
+		t.Logf("%s", report)
+
+		if report.Inlined != tst.Inlined || report.TailCalls != tst.TailCalls {
+			t.Logf("Inlined %d, tail calls %d; expected %d, %d",
+				report.Inlined, report.TailCalls, tst.Inlined, tst.TailCalls)
+			t.Fail()
+		}
+
+		if strings.Join(report.Removed, ",") != strings.Join(tst.Removed, ",") {
+			t.Logf("Removed %q; expected %q", report.Removed, tst.Removed)
+			t.Fail()
+		}
+
+		if report.InstructionsAfter != len(out) {
+			t.Logf("Report size %d doesn't match output %d", report.InstructionsAfter, len(out))
+			t.Fail()
+		}
+
+		received := run(t, out)
+		if received != expected {
+			t.Logf("Output changed.\n Rec: %q\n Exp: %q", received, expected)
+			t.Fail()
+		}
+	}
+}
+
+func TestNthLabel(t *testing.T) {
+	expected := []string{" ", "\t", "  ", " \t", "\t ", "\t\t", "   "}
+	for n, exp := range expected {
+		if lbl := inst.NthLabel(n); lbl != exp {
+			t.Logf("NthLabel(%d) = %q; expected %q", n, lbl, exp)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package optimize
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+type ShrinkReport struct {
+	// Size of the program before and after, in bytes.
+	BytesBefore int
+	BytesAfter  int
+
+	// Bytes saved by renaming labels.
+	LabelBytes int
+
+	// Bytes saved by re-encoding numbers.  Leading zeros are only counted
+	// if the original size was given to Shrink().
+	NumberBytes int
+
+	// Original label to new label.
+	Labels map[string]string
+}
+
+func (r ShrinkReport) String() string {
+	sb := &strings.Builder{}
+	fmt.Fprintf(sb, "bytes: %d -> %d (saved %d)\n", r.BytesBefore, r.BytesAfter, r.BytesBefore-r.BytesAfter)
+	fmt.Fprintf(sb, "labels: %d renamed, saved %d\n", len(r.Labels), r.LabelBytes)
+	fmt.Fprintf(sb, "numbers: saved %d", r.NumberBytes)
+	return sb.String()
+}
+
+// Shrink renames every label to the shortest unused encoding.  Labels that
+// appear the most get the shortest encodings.  The sizes in the report are
+// of the program written with Encode(), which writes numbers in their
+// shortest form, so passing the size of the program as it was originally
+// encoded (eg, with leading zeros) lets the report include the savings from
+// that.  A size of zero or less is ignored.
+func Shrink(prog []inst.Instruction, originalSize int) ([]inst.Instruction, *ShrinkReport) {
+	counts := make(map[string]int)
+	order := []string{}
+	for _, i := range prog {
+		fc, ok := i.(inst.FlowControl)
+		if !ok {
+			continue
+		}
+
+		lbl := fc.Label()
+		if _, seen := counts[lbl]; !seen {
+			order = append(order, lbl)
+		}
+		counts[lbl]++
+	}
+
+	// Most used first, then by first appearance.
+	sort.SliceStable(order, func(a, b int) bool {
+		return counts[order[a]] > counts[order[b]]
+	})
+
+	report := &ShrinkReport{
+		BytesBefore: Size(prog),
+		Labels:      make(map[string]string),
+	}
+	for n, lbl := range order {
+		report.Labels[lbl] = inst.NthLabel(n)
+	}
+
+	out := []inst.Instruction{}
+	for _, i := range prog {
+		if fc, ok := i.(inst.FlowControl); ok {
+			i = relabel(i, report.Labels[fc.Label()])
+		}
+		out = append(out, i)
+	}
+
+	report.BytesAfter = len(Encode(out))
+	report.LabelBytes = report.BytesBefore - Size(out)
+	report.NumberBytes = Size(out) - report.BytesAfter
+	if originalSize > 0 {
+		report.NumberBytes += originalSize - report.BytesBefore
+		report.BytesBefore = originalSize
+	}
+
+	return out, report
+}
+
+// Encode returns the program as whitespace with every number in its
+// shortest form.  Unlike Wsp(), zero is written as just its sign with no
+// digits.
+func Encode(prog []inst.Instruction) []byte {
+	buf := &bytes.Buffer{}
+	for _, i := range prog {
+		zero := false
+		switch i := i.(type) {
+		case *inst.Push:
+			zero = i.Value == 0
+		case *inst.Copy:
+			zero = i.Value == 0
+		case *inst.Slide:
+			zero = i.Value == 0
+		}
+
+		// drop the zero digit after the sign
+		ws := i.Wsp()
+		if zero {
+			ws = ws[:len(ws)-2] + "\n"
+		}
+		buf.WriteString(ws)
+	}
+	return buf.Bytes()
+}
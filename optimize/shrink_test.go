@@ -0,0 +1,94 @@
+package optimize
+
+import (
+	"testing"
+
+	inst "github.com/zorchenhimer/whitespace/instructions"
+)
+
+func TestShrink(t *testing.T) {
+	input := []inst.Instruction{
+		&inst.Label{Value: "\t\t\t\t"},
+		&inst.Push{Value: 0},
+		&inst.JumpZero{Value: "   "},
+		&inst.Call{Value: "\t \t \t"},
+		&inst.Call{Value: "\t \t \t"},
+		&inst.Jump{Value: "\t\t\t\t"},
+		&inst.Label{Value: "   "},
+		&inst.Stop{},
+		&inst.Label{Value: "\t \t \t"},
+		&inst.Return{},
+	}
+
+	expected := map[string]string{
+		"\t \t \t": " ",
+		"\t\t\t\t": "\t",
+		"   ":      "  ",
+	}
+
+	// push 0 with an extra leading zero
+	original := Size(input) + 1
+
+	out, report := Shrink(input, original)
+	t.Logf("%s", report)
+
+	for old, exp := range expected {
+		if report.Labels[old] != exp {
+			t.Logf("Label %q renamed to %q; expected %q", old, report.Labels[old], exp)
+			t.Fail()
+		}
+	}
+
+	// the extra leading zero and the zero digit
+	if report.NumberBytes != 2 {
+		t.Logf("Number savings %d; expected 2", report.NumberBytes)
+		t.Fail()
+	}
+
+	// 12 + 6 + 2 bytes saved by the three labels
+	if report.LabelBytes != 20 {
+		t.Logf("Label savings %d; expected 20", report.LabelBytes)
+		t.Fail()
+	}
+
+	if report.BytesBefore-report.BytesAfter != 22 || report.BytesAfter != len(Encode(out)) {
+		t.Logf("Total savings %d; expected 22", report.BytesBefore-report.BytesAfter)
+		t.Fail()
+	}
+
+	if !instEqual(t, out, input) {
+		t.Logf("Instructions changed")
+		t.Fail()
+	}
+}
+
+func TestEncode(t *testing.T) {
+	prog := []inst.Instruction{
+		&inst.Push{Value: 0},
+		&inst.Push{Value: -2},
+		&inst.Copy{Value: 0},
+		&inst.Slide{Value: 0},
+		&inst.Push{Value: 4},
+	}
+	expected := "   \n" + "  \t\t \n" + " \t  \n" + " \t\n \n" + "   \t  \n"
+
+	if out := string(Encode(prog)); out != expected {
+		t.Fatalf("Unexpected encoding.\n Rec: %q\n Exp: %q", out, expected)
+	}
+}
+
+func instEqual(t *testing.T, a, b []inst.Instruction) bool {
+	t.Helper()
+
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := 0; i < len(a); i++ {
+		if a[i].Type() != b[i].Type() {
+			t.Logf("%v != %v", a[i].Type(), b[i].Type())
+			return false
+		}
+	}
+	return true
+}
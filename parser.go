@@ -19,8 +19,6 @@ func NewParser(reader *Reader) *Parser {
 }
 
 func (p *Parser) Parse() ([]inst.Instruction, error) {
-	var err error
-	var r rune
 	cmds := []inst.Instruction{}
 
 	if p.Debug {
@@ -37,64 +35,20 @@ func (p *Parser) Parse() ([]inst.Instruction, error) {
 	}
 
 	for {
-		r, _, err = p.r.ReadRune()
+		cmd, err := p.next()
 		if err != nil {
 			if err == io.EOF {
 				break
 			}
-			return nil, err
-		}
-
-		var cmd inst.Instruction
-		switch r {
-			case ' ':
-				// stack
-				cmd, err = p.parseStack()
-			case '\n':
-				// flow control
-				cmd, err = p.parseFlow()
-				if err != nil {
-					return nil, fmt.Errorf("flow parse error: %q", err)
-				}
-				if cmd.Type() == inst.CmdLabel{
-					lbl := cmd.(*inst.Label)
-					if _, exist := p.labels[lbl.Value]; exist {
-						return nil, fmt.Errorf("Duplicate label %q", lbl.Value)
-					}
-					p.labels[lbl.Value] = len(cmds)
-				}
-			case '\t':
-				// parse next rune to complete IMP
-				r, _, err = p.r.ReadRune()
-				if err != nil {
-					return nil, fmt.Errorf("Broken IMP")
-				}
-
-				switch r {
-				case ' ':
-					// math
-					cmd, err = p.parseMath()
-				case '\t':
-					// heap
-					cmd, err = p.parseHeap()
-				case '\n':
-					// I/O
-					cmd, err = p.parseIO()
-				default:
-					cmd = nil
-					err = fmt.Errorf("Bad tab IMP")
-				}
-
-			default:
-				cmd = nil
-				err = fmt.Errorf("Bad IMP")
+			return cmds, err
 		}
 
-		if err != nil {
-			if err == io.EOF {
-				break
+		if cmd.Type() == inst.CmdLabel{
+			lbl := cmd.(*inst.Label)
+			if _, exist := p.labels[lbl.Value]; exist {
+				return nil, fmt.Errorf("Duplicate label %q", lbl.Value)
 			}
-			return cmds, err
+			p.labels[lbl.Value] = len(cmds)
 		}
 
 		cmds = append(cmds, cmd)
@@ -103,6 +57,48 @@ func (p *Parser) Parse() ([]inst.Instruction, error) {
 	return cmds, nil
 }
 
+// next parses one instruction.  It returns io.EOF if there are no more.
+func (p *Parser) next() (inst.Instruction, error) {
+	r, _, err := p.r.ReadRune()
+	if err != nil {
+		return nil, err
+	}
+
+	switch r {
+		case ' ':
+			// stack
+			return p.parseStack()
+		case '\n':
+			// flow control
+			cmd, err := p.parseFlow()
+			if err != nil {
+				return nil, fmt.Errorf("flow parse error: %q", err)
+			}
+			return cmd, nil
+		case '\t':
+			// parse next rune to complete IMP
+			r, _, err = p.r.ReadRune()
+			if err != nil {
+				return nil, fmt.Errorf("Broken IMP")
+			}
+
+			switch r {
+			case ' ':
+				// math
+				return p.parseMath()
+			case '\t':
+				// heap
+				return p.parseHeap()
+			case '\n':
+				// I/O
+				return p.parseIO()
+			}
+			return nil, fmt.Errorf("Bad tab IMP")
+	}
+
+	return nil, fmt.Errorf("Bad IMP")
+}
+
 func (p *Parser) parseIO() (inst.Instruction, error) {
 	runes := []rune{}
 	for i := 0; i < 2; i++ {
@@ -9,12 +9,38 @@ import (
 // Reader that ignores everything other than space, tab, and newline
 type Reader struct {
 	base *bufio.Reader
+	visible bool
+	offset int // bytes read from base
 }
 
 func NewReader(r io.Reader) *Reader {
 	return &Reader{base: bufio.NewReader(r)}
 }
 
+// NewVisibleReader returns a Reader for the visible notation, where S or ·
+// is a space, T or → is a tab, and L or ¶ is a newline.  Everything else is
+// ignored, including actual whitespace.
+func NewVisibleReader(r io.Reader) *Reader {
+	return &Reader{base: bufio.NewReader(r), visible: true}
+}
+
+// translate returns the whitespace a rune stands for, if any.
+func (reader *Reader) translate(r rune) (rune, bool) {
+	if !reader.visible {
+		return r, r == ' ' || r == '\t' || r == '\n'
+	}
+
+	switch r {
+	case 'S', '·':
+		return ' ', true
+	case 'T', '→':
+		return '\t', true
+	case 'L', '¶':
+		return '\n', true
+	}
+	return r, false
+}
+
 func (reader *Reader) Read(p []byte) (int, error) {
 	if len(p) == 0 {
 		return 0, fmt.Errorf("zero length buffer")
@@ -27,14 +53,13 @@ func (reader *Reader) Read(p []byte) (int, error) {
 
 	for read < len(p) && err == nil {
 		r, n, err = reader.base.ReadRune()
+		reader.offset += n
 
 		if n != 0 {
-			switch r {
-			case ' ', '\t', '\n':
-				p[read] = byte(r)
+			// ignore everything else
+			if ws, ok := reader.translate(r); ok {
+				p[read] = byte(ws)
 				read++
-			default:
-				// ignore everything else
 			}
 		} else {
 			return read, io.EOF
@@ -49,12 +74,14 @@ func (reader *Reader) Read(p []byte) (int, error) {
 	return read, err
 }
 
-func (r *Reader) ReadRune() (rune, int, error) {
+func (reader *Reader) ReadRune() (rune, int, error) {
 	for {
-		r, n, err := r.base.ReadRune()
+		r, n, err := reader.base.ReadRune()
+		reader.offset += n
+		ws, ok := reader.translate(r)
 		if err != nil {
-			if r == ' ' || r == '\t' || r == '\n' {
-				return r, n, err
+			if ok && n > 0 {
+				return ws, 1, err
 			}
 
 			if err == io.EOF {
@@ -66,8 +93,8 @@ func (r *Reader) ReadRune() (rune, int, error) {
 			return '\uFFFD', 1, err
 		}
 
-		if r == ' ' || r == '\t' || r == '\n' {
-			return r, n, nil
+		if ok {
+			return ws, 1, nil
 		}
 	}
 
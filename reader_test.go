@@ -61,3 +61,51 @@ func TestReadRune(t *testing.T) {
 		}
 	}
 }
+
+func TestVisibleReader(t *testing.T) {
+	tests := []struct{
+		input string
+		expected string
+	}{
+		{"SSTL", "  \t\n"},
+		{"S S\tT\nL", "  \t\n"},
+		{"push: SS STL", "   \t\n"},
+		{"··→¶", "  \t\n"},
+		{"S·T→L¶ st", "  \t\t\n\n"},
+	}
+
+	for id, tst := range tests {
+		reader := NewVisibleReader(strings.NewReader(tst.input))
+		out := []rune{}
+
+		for {
+			r, n, err := reader.ReadRune()
+			if err != nil && err != io.EOF {
+				t.Fatalf("[%d] ReadRune() returned error: %s", id, err)
+			}
+
+			if n > 0 {
+				out = append(out, r)
+			}
+
+			if err == io.EOF {
+				break
+			}
+		}
+
+		if string(out) != tst.expected {
+			t.Logf("[%d] Received %q; expected %q", id, string(out), tst.expected)
+			t.Fail()
+		}
+
+		buf, err := io.ReadAll(NewVisibleReader(strings.NewReader(tst.input)))
+		if err != nil {
+			t.Fatalf("[%d] Read() returned error: %s", id, err)
+		}
+
+		if string(buf) != tst.expected {
+			t.Logf("[%d] Read() received %q; expected %q", id, string(buf), tst.expected)
+			t.Fail()
+		}
+	}
+}